@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"os"
 	"path/filepath"
@@ -9,31 +10,187 @@ import (
 	"github.com/garfunkel/nasimport/nasimporter"
 )
 
+func runCache(importer *nasimporter.NasImporter, args []string) {
+	if len(args) != 1 {
+		log.Fatal("Usage: nasimport cache clear|stats")
+	}
+
+	switch args[0] {
+		case "clear":
+			if err := importer.ClearCache(); err != nil {
+				log.Fatal(err)
+			}
+
+			fmt.Println("Cache cleared.")
+
+		case "stats":
+			stats, err := importer.CacheStats()
+
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			for _, bucketStats := range stats {
+				fmt.Printf("%v: %v entries (%v expired, %v negative), %v bytes\n", bucketStats.Bucket, bucketStats.Entries, bucketStats.Expired, bucketStats.Negative, bucketStats.Size)
+			}
+
+		default:
+			log.Fatal("Usage: nasimport cache clear|stats")
+	}
+}
+
+func runUndo(importer *nasimporter.NasImporter, args []string) {
+	undoFlags := flag.NewFlagSet("undo", flag.ExitOnError)
+	last := undoFlags.Int("last", 1, "undo the last N imports")
+	batch := undoFlags.String("batch", "", "undo every import in the given batch")
+
+	undoFlags.Parse(args)
+
+	if *last < 1 {
+		log.Fatal("--last must be at least 1")
+	}
+
+	results, err := importer.Undo(*last, *batch)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	numUndone := 0
+
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("Failed to undo %v: %v\n", result.Path, result.Err)
+		} else {
+			fmt.Printf("Undid %v\n", result.Path)
+			numUndone++
+		}
+	}
+
+	fmt.Printf("%v of %v imports undone.\n", numUndone, len(results))
+}
+
+func runReview(importer *nasimporter.NasImporter) {
+	results, err := importer.Review()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	numImported := 0
+
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("%v: %v\n", result.Path, result.Err)
+		} else {
+			numImported++
+		}
+	}
+
+	fmt.Printf("%v of %v queued files imported.\n", numImported, len(results))
+}
+
 func main() {
 	defaultConfigPath := filepath.Join(filepath.Dir(os.Args[0]), "config.json")
 
-	automaticMode := flag.Bool("a", false, "automatic mode (accept best content guess)")
+	automaticMode := flag.Bool("a", false, "automatic mode (accept confident matches, queue the rest for review)")
 	configPath := flag.String("c", defaultConfigPath, "config JSON file to read in")
+	dryRun := flag.Bool("n", false, "dry-run mode (show planned imports without touching files)")
+	outputFormat := flag.String("output", "text", "output format, text or json (one JSON object per file on stdout)")
 
 	flag.Parse()
 
-	importer, err := nasimporter.NewNasImporter(*configPath, *automaticMode)
+	if *outputFormat != "text" && *outputFormat != "json" {
+		log.Fatal("-output must be text or json")
+	}
+
+	options := nasimporter.Options{AutomaticMode: *automaticMode, DryRun: *dryRun, JSONOutput: *outputFormat == "json"}
+	out := os.Stdout
+
+	if options.JSONOutput {
+		out = os.Stderr
+	}
+
+	// Watch mode runs unattended, ambiguous matches are queued for review rather than guessed.
+	if flag.Arg(0) == "watch" {
+		options.AutomaticMode = true
+	}
+
+	importer, err := nasimporter.NewNasImporter(*configPath, options, nasimporter.NewOnlineProvider())
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	numImported := 0
+	// Only importing needs the library, which can take a while to index.
+	if command := flag.Arg(0); command != "cache" && command != "undo" {
+		if err = importer.ReadExistingMedia(); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	for _, path := range flag.Args() {
-		err = importer.Import(path)
+	switch flag.Arg(0) {
+		case "cache":
+			runCache(&importer, flag.Args()[1 :])
 
-		if err != nil {
-			log.Fatal(err)
+			return
+
+		case "undo":
+			runUndo(&importer, flag.Args()[1 :])
+
+			return
+
+		case "watch":
+			if flag.NArg() != 2 {
+				log.Fatal("Usage: nasimport watch <dir>")
+			}
+
+			if err := importer.Watch(flag.Arg(1)); err != nil {
+				log.Fatal(err)
+			}
+
+			return
+
+		case "review":
+			runReview(&importer)
+
+			return
+	}
+
+	failures := []nasimporter.ImportResult{}
+	numImported := 0
+	numQueued := 0
+
+	// Keep going after failures so that one bad file doesn't abort the whole batch. Ambiguous files queued for
+	// review aren't failures, they are waiting for `nasimport review`.
+	for _, result := range importer.Import(flag.Args()...) {
+		if errors.Is(result.Err, nasimporter.ErrQueuedForReview) {
+			fmt.Fprintf(out, "Queued %v for review\n", result.Path)
+			numQueued++
+		} else if result.Err != nil {
+			fmt.Fprintf(out, "Failed to import %v: %v\n", result.Path, result.Err)
+			failures = append(failures, result)
 		} else {
+			fmt.Fprintf(out, "Imported %v\n", result.Path)
 			numImported++
 		}
 	}
 
-	fmt.Printf("%v files imported.\n", numImported)
+	if *dryRun {
+		fmt.Fprintf(out, "\n%v files planned, %v to queue for review, %v failed, nothing was changed.\n", numImported, numQueued, len(failures))
+	} else {
+		fmt.Fprintf(out, "\n%v files imported, %v queued for review, %v failed.\n", numImported, numQueued, len(failures))
+
+		if numImported > 0 {
+			fmt.Fprintf(out, "Batch %v, undo with: nasimport undo --batch %v\n", importer.Batch(), importer.Batch())
+		}
+	}
+
+	for _, failure := range failures {
+		fmt.Fprintf(out, "\t%v: %v\n", failure.Path, failure.Err)
+	}
+
+	if len(failures) > 0 {
+		os.Exit(1)
+	}
 }
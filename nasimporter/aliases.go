@@ -0,0 +1,185 @@
+package nasimporter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Alias sends every release parsed to a given name straight to one match, skipping searches and prompts.
+type Alias struct {
+	Source MediaSource
+	Id string
+}
+
+// aliasShorthands are the alias sources which don't need spelling out in full.
+var aliasShorthands = map[string]MediaSource{
+	"tvdb": TVTVDB,
+	"imdb": MovieIMDB,
+	"local": TVLocal,
+}
+
+// parseAlias reads an alias target such as "tvdb:78804", "imdb:tt0436992" or "local:Doctor Who", or one naming the
+// media source in full, e.g. "documentary_imdb:tt0123456".
+func parseAlias(target string) (alias Alias, err error) {
+	parts := strings.SplitN(target, ":", 2)
+
+	if len(parts) != 2 || parts[1] == "" {
+		err = errors.New(fmt.Sprintf("Invalid alias %q: expected source:id.", target))
+
+		return
+	}
+
+	alias.Id = parts[1]
+
+	if source, ok := aliasShorthands[parts[0]]; ok {
+		alias.Source = source
+	} else {
+		for _, source := range []MediaSource{TVTVDB, DocumentaryTVDB, DocumentaryIMDB, MovieIMDB, TVLocal, DocumentaryLocal} {
+			if source.String() == parts[0] {
+				alias.Source = source
+			}
+		}
+	}
+
+	if alias.Source == Unknown {
+		err = errors.New(fmt.Sprintf("Invalid alias %q: unknown source %q.", target, parts[0]))
+
+		return
+	}
+
+	if alias.Source == TVTVDB || alias.Source == DocumentaryTVDB {
+		if _, err = strconv.ParseUint(alias.Id, 10, 64); err != nil {
+			err = errors.New(fmt.Sprintf("Invalid alias %q: TheTVDB IDs are numbers.", target))
+		}
+	}
+
+	return
+}
+
+// aliasKey is the normalized form of a name, with its year if known, e.g. "doctor who (2005)".
+func aliasKey(title string, year uint64) (key string) {
+	key = strings.Join(normalizeTitle(title), " ")
+
+	if year != 0 {
+		key += fmt.Sprintf(" (%v)", year)
+	}
+
+	return
+}
+
+func (source MediaSource) mediaType() MediaType {
+	switch source {
+		case DocumentaryTVDB, DocumentaryIMDB, DocumentaryLocal:
+			return Documentary
+
+		case MovieIMDB:
+			return Movie
+	}
+
+	return TV
+}
+
+func (importer *NasImporter) compileAliases() (err error) {
+	importer.aliases = map[string]Alias{}
+
+	for name, target := range importer.config.Aliases {
+		alias, err := parseAlias(target)
+
+		if err != nil {
+			return err
+		}
+
+		title := name
+		year := uint64(0)
+
+		if fields := importer.directoryRegex.FindStringSubmatchMap(name); fields != nil {
+			title = fields["name"]
+			year, _ = strconv.ParseUint(fields["year"], 10, 64)
+		}
+
+		importer.aliases[aliasKey(title, year)] = alias
+	}
+
+	return
+}
+
+// findAlias looks up a release by name and year, then by name alone.
+func (importer *NasImporter) findAlias(release *ParsedRelease, mediaType MediaType) (alias Alias, ok bool) {
+	if release == nil {
+		return
+	}
+
+	for _, key := range []string{aliasKey(release.Title, release.Year), aliasKey(release.Title, 0)} {
+		if alias, ok = importer.aliases[key]; ok && alias.Source.mediaType() == mediaType {
+			return
+		}
+	}
+
+	return Alias{}, false
+}
+
+// resolveAlias fetches what an alias points at, as the only candidate for a file. Warnings go to output.
+func (importer *NasImporter) resolveAlias(output io.Writer, alias Alias) (scoreItem ScoreItem, err error) {
+	scoreItem = ScoreItem{value: alias.Id, score: 1, source: alias.Source, data: alias.Id}
+
+	switch alias.Source {
+		case TVTVDB, DocumentaryTVDB:
+			id, _ := strconv.ParseUint(alias.Id, 10, 64)
+			series, err := importer.getTVDBSeries(output, id)
+
+			if err != nil {
+				return scoreItem, err
+			}
+
+			scoreItem.value = series.SeriesName
+			scoreItem.data = series
+
+		case DocumentaryIMDB, MovieIMDB:
+			title, err := importer.getIMDBTitle(output, alias.Id)
+
+			if err != nil {
+				return scoreItem, err
+			}
+
+			scoreItem.value = title.Name
+			scoreItem.data = title
+	}
+
+	return
+}
+
+// detectAlias checks the file against the alias table, and if it is listed makes the alias its only candidate.
+func (importer *NasImporter) detectAlias(lookup *fileLookup) (found bool, err error) {
+	releases := map[MediaType]*ParsedRelease{}
+
+	for _, mediaType := range []MediaType{TV, Documentary, Movie} {
+		releases[mediaType], _ = importer.ParsePath(lookup.path, mediaType)
+
+		alias, ok := importer.findAlias(releases[mediaType], mediaType)
+
+		if !ok {
+			continue
+		}
+
+		scoreItem, err := importer.resolveAlias(&lookup.output, alias)
+
+		if err != nil {
+			return false, err
+		}
+
+		fmt.Fprintf(&lookup.output, "Importing %s\n", lookup.path)
+		fmt.Fprintf(&lookup.output, "Using alias for %v: %v\n", releases[mediaType].Title, getProviderId(scoreItem.data))
+		lookup.tvShowRelease = releases[TV]
+		lookup.documentaryRelease = releases[Documentary]
+		lookup.movieRelease = releases[Movie]
+		lookup.absoluteOrder = ScoreItems{scoreItem}
+		lookup.aliased = true
+
+		return true, nil
+	}
+
+	return
+}
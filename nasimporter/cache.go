@@ -0,0 +1,202 @@
+package nasimporter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	Expires time.Time `json:"expires"`
+	Negative bool `json:"negative,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+type CacheStats struct {
+	Bucket string
+	Entries int
+	Expired int
+	Negative int
+	Size int64
+}
+
+// MetadataCache stores provider search results on disk, one JSON file per bucket.
+type MetadataCache struct {
+	dir string
+	ttl time.Duration
+	negativeTTL time.Duration
+	mutex sync.Mutex
+	buckets map[string]map[string]cacheEntry
+}
+
+func NewMetadataCache(dir string, ttl, negativeTTL time.Duration) *MetadataCache {
+	return &MetadataCache{
+		dir: dir,
+		ttl: ttl,
+		negativeTTL: negativeTTL,
+		buckets: map[string]map[string]cacheEntry{},
+	}
+}
+
+func (cache *MetadataCache) bucketPath(bucket string) string {
+	return filepath.Join(cache.dir, bucket + ".json")
+}
+
+func (cache *MetadataCache) loadBucket(bucket string) (entries map[string]cacheEntry) {
+	entries, ok := cache.buckets[bucket]
+
+	if ok {
+		return
+	}
+
+	entries = map[string]cacheEntry{}
+	cache.buckets[bucket] = entries
+	bucketBytes, err := ioutil.ReadFile(cache.bucketPath(bucket))
+
+	if err != nil {
+		return
+	}
+
+	// A corrupt bucket is treated as empty and will be overwritten on the next save.
+	if err = json.Unmarshal(bucketBytes, &entries); err != nil {
+		entries = map[string]cacheEntry{}
+		cache.buckets[bucket] = entries
+	}
+
+	return
+}
+
+func (cache *MetadataCache) saveBucket(bucket string) (err error) {
+	entries := cache.loadBucket(bucket)
+	now := time.Now()
+
+	for key, entry := range entries {
+		if now.After(entry.Expires) {
+			delete(entries, key)
+		}
+	}
+
+	bucketBytes, err := json.Marshal(entries)
+
+	if err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(cache.dir, os.ModeDir | 0755); err != nil {
+		return
+	}
+
+	// Write to a temporary file first so a crash never leaves a half-written bucket.
+	tempPath := cache.bucketPath(bucket) + ".tmp"
+
+	if err = ioutil.WriteFile(tempPath, bucketBytes, 0644); err != nil {
+		return
+	}
+
+	err = os.Rename(tempPath, cache.bucketPath(bucket))
+
+	return
+}
+
+func (cache *MetadataCache) Get(bucket, key string, value interface{}) (ok bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entry, ok := cache.loadBucket(bucket)[key]
+
+	if !ok || time.Now().After(entry.Expires) {
+		return false
+	}
+
+	return json.Unmarshal(entry.Value, value) == nil
+}
+
+// Set stores value under key. Negative entries record that a search found nothing and expire sooner.
+func (cache *MetadataCache) Set(bucket, key string, value interface{}, negative bool) (err error) {
+	valueBytes, err := json.Marshal(value)
+
+	if err != nil {
+		return
+	}
+
+	ttl := cache.ttl
+
+	if negative {
+		ttl = cache.negativeTTL
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.loadBucket(bucket)[key] = cacheEntry{Expires: time.Now().Add(ttl), Negative: negative, Value: valueBytes}
+	err = cache.saveBucket(bucket)
+
+	return
+}
+
+func (cache *MetadataCache) Clear() (err error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	bucketPaths, err := filepath.Glob(filepath.Join(cache.dir, "*.json"))
+
+	if err != nil {
+		return
+	}
+
+	for _, bucketPath := range bucketPaths {
+		if err = os.Remove(bucketPath); err != nil {
+			return
+		}
+	}
+
+	cache.buckets = map[string]map[string]cacheEntry{}
+
+	return
+}
+
+func (cache *MetadataCache) Stats() (stats []CacheStats, err error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	bucketPaths, err := filepath.Glob(filepath.Join(cache.dir, "*.json"))
+
+	if err != nil {
+		return
+	}
+
+	sort.Strings(bucketPaths)
+	now := time.Now()
+
+	for _, bucketPath := range bucketPaths {
+		fileInfo, err := os.Stat(bucketPath)
+
+		if err != nil {
+			continue
+		}
+
+		bucket := filepath.Base(bucketPath)
+		bucket = bucket[: len(bucket) - len(".json")]
+		bucketStats := CacheStats{Bucket: bucket, Size: fileInfo.Size()}
+
+		for _, entry := range cache.loadBucket(bucket) {
+			bucketStats.Entries++
+
+			if now.After(entry.Expires) {
+				bucketStats.Expired++
+			}
+
+			if entry.Negative {
+				bucketStats.Negative++
+			}
+		}
+
+		stats = append(stats, bucketStats)
+	}
+
+	return
+}
@@ -0,0 +1,152 @@
+package nasimporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Choice is the match picked by hand for a parsed name.
+type Choice struct {
+	Source string `json:"source"`
+	ProviderId string `json:"provider_id"`
+	Time time.Time `json:"time"`
+}
+
+// ChoiceStore remembers interactive choices in a JSON file, keyed by media type and normalized name.
+type ChoiceStore struct {
+	path string
+	choices map[string]Choice
+	mutex sync.Mutex
+}
+
+func NewChoiceStore(path string) *ChoiceStore {
+	return &ChoiceStore{path: path}
+}
+
+func (store *ChoiceStore) load() (err error) {
+	if store.choices != nil {
+		return
+	}
+
+	// Only keep what was read in full, a Set after a failed load would otherwise overwrite every choice.
+	choices := map[string]Choice{}
+	choicesBytes, err := ioutil.ReadFile(store.path)
+
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	if err == nil {
+		if err = json.Unmarshal(choicesBytes, &choices); err != nil {
+			return
+		}
+	}
+
+	store.choices = choices
+
+	return nil
+}
+
+func (store *ChoiceStore) Get(key string) (choice Choice, ok bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.load() != nil {
+		return
+	}
+
+	choice, ok = store.choices[key]
+
+	return
+}
+
+func (store *ChoiceStore) Set(key string, choice Choice) (err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if err = store.load(); err != nil {
+		return
+	}
+
+	choice.Time = time.Now()
+	store.choices[key] = choice
+	choicesBytes, err := json.MarshalIndent(store.choices, "", "\t")
+
+	if err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(store.path), os.ModeDir | 0755); err != nil {
+		return
+	}
+
+	// Write to a temporary file first so an interrupted write can't lose every choice.
+	tmpPath := store.path + ".tmp"
+
+	if err = ioutil.WriteFile(tmpPath, choicesBytes, 0644); err != nil {
+		return
+	}
+
+	err = os.Rename(tmpPath, store.path)
+
+	return
+}
+
+// choiceKey identifies a parsed name, e.g. "tv|doctor who (2005)".
+func choiceKey(mediaType MediaType, release *ParsedRelease) string {
+	mediaTypeNames := map[MediaType]string{TV: "tv", Documentary: "documentary", Movie: "movie"}
+
+	return fmt.Sprintf("%v|%v", mediaTypeNames[mediaType], aliasKey(release.Title, release.Year))
+}
+
+func (lookup *fileLookup) release(mediaType MediaType) *ParsedRelease {
+	switch mediaType {
+		case TV:
+			return lookup.tvShowRelease
+
+		case Documentary:
+			return lookup.documentaryRelease
+	}
+
+	return lookup.movieRelease
+}
+
+// markPreviousChoices flags the candidates picked by hand for the same name before and ranks them first.
+func (importer *NasImporter) markPreviousChoices(lookup *fileLookup) {
+	for index, scoreItem := range lookup.absoluteOrder {
+		mediaType := scoreItem.source.mediaType()
+		release := lookup.release(mediaType)
+
+		if release == nil {
+			continue
+		}
+
+		choice, ok := importer.choices.Get(choiceKey(mediaType, release))
+
+		if ok && choice.Source == scoreItem.source.String() && choice.ProviderId == getProviderId(scoreItem.data) {
+			lookup.absoluteOrder[index].previouslyChosen = true
+		}
+	}
+
+	sort.Sort(lookup.absoluteOrder)
+}
+
+// rememberChoice records an interactive choice so the same name is matched the same way next time.
+func (importer *NasImporter) rememberChoice(lookup *fileLookup, scoreItem ScoreItem) (err error) {
+	mediaType := scoreItem.source.mediaType()
+	release := lookup.release(mediaType)
+
+	if release == nil {
+		return
+	}
+
+	err = importer.choices.Set(choiceKey(mediaType, release), Choice{Source: scoreItem.source.String(), ProviderId: getProviderId(scoreItem.data)})
+
+	return
+}
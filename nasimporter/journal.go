@@ -0,0 +1,246 @@
+package nasimporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const UndoMethod ImportMethod = "undo"
+
+type JournalEntry struct {
+	Id string `json:"id"`
+	Batch string `json:"batch"`
+	Time time.Time `json:"time"`
+	Source string `json:"source"`
+	Parts []string `json:"parts,omitempty"`
+	Destination string `json:"destination"`
+	Method ImportMethod `json:"method"`
+	ProviderId string `json:"provider_id,omitempty"`
+	Undoes string `json:"undoes,omitempty"`
+}
+
+// Journal is an append-only log of every file operation, one JSON object per line.
+type Journal struct {
+	path string
+	batch string
+	sequence int
+	mutex sync.Mutex
+}
+
+func NewJournal(path string) *Journal {
+	return &Journal{
+		path: path,
+		batch: fmt.Sprintf("%v-%v", time.Now().Format("20060102-150405"), os.Getpid()),
+	}
+}
+
+// Batch identifies the imports of this run, for undoing them together.
+func (journal *Journal) Batch() string {
+	return journal.batch
+}
+
+func (journal *Journal) Record(entry JournalEntry) (err error) {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	journal.sequence++
+	entry.Batch = journal.batch
+	entry.Id = fmt.Sprintf("%v-%v", journal.batch, journal.sequence)
+	entry.Time = time.Now()
+	entryBytes, err := json.Marshal(entry)
+
+	if err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(journal.path), os.ModeDir | 0755); err != nil {
+		return
+	}
+
+	handle, err := os.OpenFile(journal.path, os.O_WRONLY | os.O_APPEND | os.O_CREATE, 0644)
+
+	if err != nil {
+		return
+	}
+
+	defer handle.Close()
+
+	_, err = handle.Write(append(entryBytes, '\n'))
+
+	return
+}
+
+func (journal *Journal) Read() (entries []JournalEntry, err error) {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	handle, err := os.Open(journal.path)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+
+	defer handle.Close()
+
+	scanner := bufio.NewScanner(handle)
+
+	for scanner.Scan() {
+		entry := JournalEntry{}
+
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			err = errors.New(fmt.Sprintf("Corrupt journal entry in %v: %v", journal.path, err))
+
+			return
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err = scanner.Err()
+
+	return
+}
+
+// undoableEntries returns the import entries that haven't been undone yet, oldest first.
+func (journal *Journal) undoableEntries() (entries []JournalEntry, err error) {
+	allEntries, err := journal.Read()
+
+	if err != nil {
+		return
+	}
+
+	undone := make(map[string]struct{})
+
+	for _, entry := range allEntries {
+		if entry.Method == UndoMethod {
+			undone[entry.Undoes] = struct{}{}
+		}
+	}
+
+	for _, entry := range allEntries {
+		if _, ok := undone[entry.Id]; entry.Method != UndoMethod && !ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	return
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+
+	return err == nil
+}
+
+func (importer *NasImporter) undoEntry(entry JournalEntry) (err error) {
+	if !pathExists(entry.Destination) {
+		err = errors.New(fmt.Sprintf("%v no longer exists.", entry.Destination))
+
+		return
+	}
+
+	if entry.Method == RenameMethod {
+		if pathExists(entry.Source) {
+			err = errors.New(fmt.Sprintf("Can't move back, %v already exists.", entry.Source))
+
+			return
+		}
+
+		if importer.dryRun {
+			fmt.Fprintf(importer.out, "Plan: move %v -> %v\n", entry.Destination, entry.Source)
+
+			return
+		}
+
+		if err = os.MkdirAll(filepath.Dir(entry.Source), os.ModeDir | 0755); err != nil {
+			return
+		}
+
+		err = os.Rename(entry.Destination, entry.Source)
+	} else {
+		// Remuxed outputs are copies, only delete them while the original, or every part of it, is still around.
+		sources := entry.Parts
+
+		if len(sources) == 0 {
+			sources = []string{entry.Source}
+		}
+
+		for _, source := range sources {
+			if !pathExists(source) {
+				err = errors.New(fmt.Sprintf("Refusing to delete %v, source %v is missing.", entry.Destination, source))
+
+				return
+			}
+		}
+
+		if importer.dryRun {
+			fmt.Fprintf(importer.out, "Plan: delete %v\n", entry.Destination)
+
+			return
+		}
+
+		err = os.Remove(entry.Destination)
+	}
+
+	if err != nil {
+		return
+	}
+
+	err = importer.journal.Record(JournalEntry{
+		Source: entry.Destination,
+		Destination: entry.Source,
+		Method: UndoMethod,
+		ProviderId: entry.ProviderId,
+		Undoes: entry.Id,
+	})
+
+	return
+}
+
+// Batch identifies the imports of this run, see Undo.
+func (importer *NasImporter) Batch() string {
+	return importer.journal.Batch()
+}
+
+// Undo reverts the last n journalled imports, or every import in batch if it isn't empty, newest first.
+func (importer *NasImporter) Undo(last int, batch string) (results []ImportResult, err error) {
+	entries, err := importer.journal.undoableEntries()
+
+	if err != nil {
+		return
+	}
+
+	if batch != "" {
+		batchEntries := []JournalEntry{}
+
+		for _, entry := range entries {
+			if entry.Batch == batch {
+				batchEntries = append(batchEntries, entry)
+			}
+		}
+
+		if len(batchEntries) == 0 {
+			err = errors.New(fmt.Sprintf("Nothing to undo in batch %v.", batch))
+
+			return
+		}
+
+		entries = batchEntries
+	} else if last >= 0 && last < len(entries) {
+		entries = entries[len(entries) - last :]
+	}
+
+	for index := len(entries) - 1; index >= 0; index-- {
+		results = append(results, ImportResult{Path: entries[index].Destination, Err: importer.undoEntry(entries[index])})
+	}
+
+	return
+}
@@ -0,0 +1,252 @@
+package nasimporter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LibrarySeries is a series directory in the library, with the file holding each episode by season.
+type LibrarySeries struct {
+	Name string
+	Seasons map[uint64]map[uint64]string
+}
+
+// LibraryMovie is a file in the library which isn't part of a series.
+type LibraryMovie struct {
+	Name string
+	Year uint64
+	Edition string
+	Path string
+}
+
+// Library indexes the media already imported: series, seasons and episodes under the TV and documentary
+// directories, and movies under the movie and documentary directories.
+type Library struct {
+	series map[MediaType]map[string]*LibrarySeries
+	movies map[MediaType]map[string]LibraryMovie
+	mutex sync.RWMutex
+}
+
+// libraryEntry is what the index learns from a single file, either episodes of a series or a movie.
+type libraryEntry struct {
+	mediaType MediaType
+	series string
+	season uint64
+	episodes []uint64
+	movie LibraryMovie
+}
+
+func NewLibrary() *Library {
+	return &Library{
+		series: map[MediaType]map[string]*LibrarySeries{TV: {}, Documentary: {}, Movie: {}},
+		movies: map[MediaType]map[string]LibraryMovie{TV: {}, Documentary: {}, Movie: {}},
+	}
+}
+
+func seriesKey(name string) string {
+	return strings.Join(normalizeTitle(name), " ")
+}
+
+// movieKey tells movies apart by name, year and edition, so different cuts aren't taken for copies.
+func movieKey(movie LibraryMovie) string {
+	return aliasKey(movie.Name, movie.Year) + "|" + strings.ToLower(movie.Edition)
+}
+
+func (library *Library) addSeries(mediaType MediaType, name string) *LibrarySeries {
+	key := seriesKey(name)
+	series, ok := library.series[mediaType][key]
+
+	if !ok {
+		series = &LibrarySeries{Name: name, Seasons: map[uint64]map[uint64]string{}}
+		library.series[mediaType][key] = series
+	}
+
+	return series
+}
+
+// AddSeries lists a series directory, even if no episodes are found in it.
+func (library *Library) AddSeries(mediaType MediaType, name string) {
+	library.mutex.Lock()
+	defer library.mutex.Unlock()
+
+	library.addSeries(mediaType, name)
+}
+
+func (library *Library) add(entry libraryEntry, path string) {
+	library.mutex.Lock()
+	defer library.mutex.Unlock()
+
+	if entry.series == "" {
+		entry.movie.Path = path
+		library.movies[entry.mediaType][movieKey(entry.movie)] = entry.movie
+
+		return
+	}
+
+	series := library.addSeries(entry.mediaType, entry.series)
+
+	if series.Seasons[entry.season] == nil {
+		series.Seasons[entry.season] = map[uint64]string{}
+	}
+
+	for _, episode := range entry.episodes {
+		series.Seasons[entry.season][episode] = path
+	}
+}
+
+// find returns the file already holding any of an entry's episodes, or its movie.
+func (library *Library) find(entry libraryEntry) (path string, ok bool) {
+	library.mutex.RLock()
+	defer library.mutex.RUnlock()
+
+	if entry.series == "" {
+		movie, ok := library.movies[entry.mediaType][movieKey(entry.movie)]
+
+		return movie.Path, ok
+	}
+
+	series, ok := library.series[entry.mediaType][seriesKey(entry.series)]
+
+	if !ok {
+		return
+	}
+
+	for _, episode := range entry.episodes {
+		if path, ok = series.Seasons[entry.season][episode]; ok {
+			return
+		}
+	}
+
+	return
+}
+
+// SeriesNames lists the series directories of a media type.
+func (library *Library) SeriesNames(mediaType MediaType) (names []string) {
+	library.mutex.RLock()
+	defer library.mutex.RUnlock()
+
+	for _, series := range library.series[mediaType] {
+		names = append(names, series.Name)
+	}
+
+	sort.Strings(names)
+
+	return
+}
+
+func (importer *NasImporter) mediaDir(mediaType MediaType) string {
+	switch mediaType {
+		case TV:
+			return importer.config.MediaDirs.TVDir
+
+		case Documentary:
+			return importer.config.MediaDirs.DocumentaryDir
+	}
+
+	return importer.config.MediaDirs.MovieDir
+}
+
+// libraryEntryFor reads what a file under a media directory holds. Episodes inside a directory of the TV or
+// documentary directories belong to the series named by that directory, other documentaries and movies are movies.
+func (importer *NasImporter) libraryEntryFor(mediaType MediaType, path string) (entry libraryEntry, ok bool) {
+	relPath, err := filepath.Rel(importer.mediaDir(mediaType), path)
+
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return
+	}
+
+	entry.mediaType = mediaType
+	components := strings.Split(relPath, string(filepath.Separator))
+
+	if mediaType != Movie && len(components) > 1 {
+		if release, err := importer.ParsePath(path, mediaType); err == nil && release.HasEpisode() {
+			entry.series = components[0]
+			entry.season = release.Season
+			entry.episodes = release.Episodes
+
+			return entry, true
+		}
+	}
+
+	if mediaType == TV {
+		return
+	}
+
+	release, err := importer.ParsePath(path, Movie)
+
+	if err != nil {
+		return
+	}
+
+	entry.movie = LibraryMovie{Name: release.Title, Year: release.Year, Edition: release.Edition}
+
+	return entry, true
+}
+
+// indexFile adds a file under a media directory to the library.
+func (importer *NasImporter) indexFile(mediaType MediaType, path string) {
+	if entry, ok := importer.libraryEntryFor(mediaType, path); ok {
+		importer.library.add(entry, path)
+	}
+}
+
+// findExisting returns the file in the library which already holds what would be imported to outPath.
+func (importer *NasImporter) findExisting(mediaType MediaType, outPath string) (path string, ok bool) {
+	entry, ok := importer.libraryEntryFor(mediaType, outPath)
+
+	if !ok {
+		return
+	}
+
+	return importer.library.find(entry)
+}
+
+// ReadExistingMedia indexes the library, which NewNasImporter leaves empty. Media directories which don't exist yet
+// are left empty, and parts of the library which can't be read are skipped with a warning.
+func (importer *NasImporter) ReadExistingMedia() (err error) {
+	importer.library = NewLibrary()
+
+	for _, mediaType := range []MediaType{TV, Documentary, Movie} {
+		root := importer.mediaDir(mediaType)
+
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if mediaType != Movie {
+			_, dirs, err := importer.getFilesDirs(root)
+
+			if err != nil {
+				return err
+			}
+
+			for _, dir := range dirs {
+				if !importer.isIgnoredName(dir) {
+					importer.library.AddSeries(mediaType, dir)
+				}
+			}
+		}
+
+		paths, err := importer.walkVideoFiles(root, func(path string, err error) error {
+			fmt.Fprintf(importer.out, "Warning: not indexing %v: %v\n", path, err)
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			importer.indexFile(mediaType, path)
+		}
+	}
+
+	return
+}
@@ -11,16 +11,16 @@ import (
 	"os"
 	"encoding/json"
 	"io/ioutil"
-	"log"
-	"net/http"
 	"strings"
 	"strconv"
 	"os/exec"
 	"bytes"
+	"time"
+	"text/template"
+	"io"
 	"github.com/garfunkel/go-mapregexp"
 	"github.com/garfunkel/go-tvdb"
 	"github.com/StalkR/imdb"
-	"github.com/arbovm/levenshtein"
 )
 
 type MediaType int
@@ -43,6 +43,40 @@ const (
 	DocumentaryLocal
 )
 
+var (
+	ErrNoMatch = errors.New("No match found.")
+	ErrProviderUnavailable = errors.New("Metadata provider unavailable.")
+	ErrDestinationExists = errors.New("Destination already exists.")
+	ErrMuxFailed = errors.New("Unable to remux to Matroska.")
+	ErrQueuedForReview = errors.New("Match is ambiguous, queued for review.")
+	// ErrNotFound is returned by a MetadataProvider which knows nothing by that name or ID, as opposed to one which
+	// couldn't be asked.
+	ErrNotFound = errors.New("Not found.")
+)
+
+type ImportMethod string
+
+const (
+	RenameMethod ImportMethod = "rename"
+	MKVMergeMethod ImportMethod = "mkvmerge remux"
+	FFMPEGMethod ImportMethod = "ffmpeg remux"
+	MKVMergeAppendMethod ImportMethod = "mkvmerge append"
+	FFMPEGConcatMethod ImportMethod = "ffmpeg concat"
+	// SkipMethod is planned by a dry run for a file whose destination already exists.
+	SkipMethod ImportMethod = "skip"
+)
+
+const (
+	defaultMinScore = 0.85
+	defaultMinMargin = 0.1
+)
+
+type Options struct {
+	AutomaticMode bool
+	DryRun bool
+	JSONOutput bool
+}
+
 type Config struct {
 	MediaDirs struct {
 		TVDir string `json:"tv"`
@@ -55,40 +89,127 @@ type Config struct {
 	} `json:"matroska_muxers"`
 	Interface struct {
 		NumVisibleResults int `json:"num_visible_results"`
+		LookupWorkers int `json:"lookup_workers"`
 	} `json:"interface"`
+	Cache struct {
+		Dir string `json:"dir"`
+		TTLHours int `json:"ttl_hours"`
+		NegativeTTLHours int `json:"negative_ttl_hours"`
+	} `json:"cache"`
+	Journal struct {
+		Path string `json:"path"`
+	} `json:"journal"`
+	Choices struct {
+		Path string `json:"path"`
+	} `json:"choices"`
+	Watch struct {
+		QueuePath string `json:"queue_path"`
+		SettleSeconds int `json:"settle_seconds"`
+	} `json:"watch"`
+	Templates struct {
+		TVEpisode string `json:"tv_episode"`
+		DocumentarySeries string `json:"documentary_series"`
+		DocumentaryWithYear string `json:"documentary_with_year"`
+		Documentary string `json:"documentary"`
+		Movie string `json:"movie"`
+	} `json:"templates"`
+	Automatic struct {
+		MinScore float64 `json:"min_score"`
+		MinMargin float64 `json:"min_margin"`
+	} `json:"automatic"`
+	Aliases map[string]string `json:"aliases"`
+	Patterns struct {
+		TV []string `json:"tv"`
+		Documentary []string `json:"documentaries"`
+		Movie []string `json:"movies"`
+	} `json:"patterns"`
 }
 
 type CacheKey [2]string
 
+func (cacheKey CacheKey) String() string {
+	return cacheKey[0] + "|" + cacheKey[1]
+}
+
 type NasImporter struct {
 	tvShowRegex1 *mapregexp.MapRegexp
 	tvShowRegex2 *mapregexp.MapRegexp
 	tvShowRegex3 *mapregexp.MapRegexp
 	tvShowRegex4 *mapregexp.MapRegexp
+	dailyShowRegex *mapregexp.MapRegexp
+	absoluteEpisodeRegex *mapregexp.MapRegexp
+	bareEpisodeRegex *mapregexp.MapRegexp
+	bareSeasonEpisodeRegex *mapregexp.MapRegexp
+	specialRegex *mapregexp.MapRegexp
+	directoryRegex *mapregexp.MapRegexp
+	seasonDirRegex *regexp.Regexp
+	tvPatterns []*mapregexp.MapRegexp
+	documentaryPatterns []*mapregexp.MapRegexp
+	moviePatterns []*mapregexp.MapRegexp
+	aliases map[string]Alias
 	singleDocumentaryRegex *mapregexp.MapRegexp
 	multiDocumentaryRegex *mapregexp.MapRegexp
 	yearDocumentaryRegex *mapregexp.MapRegexp
 	movieWithYearRegex *mapregexp.MapRegexp
 	movieWithoutYearRegex *mapregexp.MapRegexp
-	existingTVShowDirs []string
-	existingDocumentaryFiles []string
-	existingDocumentaryDirs []string
+	library *Library
 	tvdbWebSearchSeriesRegex *regexp.Regexp
 	wordRegex *regexp.Regexp
-	imdbClient http.Client
+	sampleRegex *regexp.Regexp
+	resolutionRegex *regexp.Regexp
+	releaseSourceRegex *regexp.Regexp
+	videoCodecRegex *regexp.Regexp
+	audioCodecRegex *regexp.Regexp
+	editionRegex *regexp.Regexp
+	languageRegex *regexp.Regexp
+	releaseGroupRegex *regexp.Regexp
+	partRegex *regexp.Regexp
+	episodeTokenRegex *regexp.Regexp
+	multiEpisodeRegex *regexp.Regexp
+	numberRegex *regexp.Regexp
+	provider MetadataProvider
 	automaticMode bool
+	dryRun bool
 	configPath string
 	config Config
-	tvdbCache map[CacheKey]tvdb.SeriesList
-	imdbCache map[CacheKey][]imdb.Title
+	cache *MetadataCache
+	journal *Journal
+	reviewQueue *ReviewQueue
+	choices *ChoiceStore
+	jsonOutput bool
+	out io.Writer
+	reportOut io.Writer
+	tvEpisodeTemplate *template.Template
+	documentarySeriesTemplate *template.Template
+	documentaryWithYearTemplate *template.Template
+	documentaryTemplate *template.Template
+	movieTemplate *template.Template
 }
 
 type ScoreItem struct {
 	value string
-	words []string
-	score int
+	score float64
 	source MediaSource
 	data interface{}
+	previouslyChosen bool
+}
+
+type ImportResult struct {
+	Path string
+	Err error
+}
+
+type fileLookup struct {
+	path string
+	parts []string
+	output bytes.Buffer
+	tvShowRelease *ParsedRelease
+	documentaryRelease *ParsedRelease
+	movieRelease *ParsedRelease
+	absoluteOrder ScoreItems
+	aliased bool
+	joined bool
+	err error
 }
 
 type ImportChoice struct {
@@ -99,15 +220,33 @@ type ImportChoice struct {
 
 type ScoreItems []ScoreItem
 
+var videoExtensions = map[string]bool{
+	".mkv": true,
+	".avi": true,
+	".mp4": true,
+	".m4v": true,
+	".mov": true,
+	".wmv": true,
+	".mpg": true,
+	".mpeg": true,
+	".ts": true,
+	".m2ts": true,
+	".flv": true,
+	".webm": true,
+	".ogm": true,
+	".divx": true,
+}
+
 func (scoreItems ScoreItems) Len() int {
 	return len(scoreItems)
 }
 
+// Less sorts previous choices first, then the most similar candidates.
 func (scoreItems ScoreItems) Less(i, j int) bool {
-	less := scoreItems[i].score < scoreItems[j].score
-
-	if scoreItems[i].score != scoreItems[j].score {
-		return less
+	if scoreItems[i].previouslyChosen != scoreItems[j].previouslyChosen {
+		return scoreItems[i].previouslyChosen
+	} else if scoreItems[i].score != scoreItems[j].score {
+		return scoreItems[i].score > scoreItems[j].score
 	} else {
 		return scoreItems[i].source < scoreItems[j].source
 	}
@@ -117,22 +256,47 @@ func (scoreItems ScoreItems) Swap(i, j int) {
 	scoreItems[i], scoreItems[j] = scoreItems[j], scoreItems[i]
 }
 
-func NewNasImporter(configPath string, automaticMode bool) (importer NasImporter, err error) {
+func NewNasImporter(configPath string, options Options, provider MetadataProvider) (importer NasImporter, err error) {
 	importer.configPath, err = filepath.Abs(configPath)
 
 	if err != nil {
 		return
 	}
 
-	importer.ReadConfig()
+	if err = importer.ReadConfig(); err != nil {
+		return
+	}
 
-	importer.tvdbCache = map[CacheKey]tvdb.SeriesList{}
-	importer.imdbCache = map[CacheKey][]imdb.Title{}
+	cacheDir := importer.config.Cache.Dir
+	cacheTTL := time.Duration(importer.config.Cache.TTLHours) * time.Hour
+	cacheNegativeTTL := time.Duration(importer.config.Cache.NegativeTTLHours) * time.Hour
+
+	cacheDir = importer.resolvePath(cacheDir, "cache")
+
+	if cacheTTL <= 0 {
+		cacheTTL = 7 * 24 * time.Hour
+	}
+
+	if cacheNegativeTTL <= 0 {
+		cacheNegativeTTL = 24 * time.Hour
+	}
+
+	importer.cache = NewMetadataCache(cacheDir, cacheTTL, cacheNegativeTTL)
+	importer.journal = NewJournal(importer.resolvePath(importer.config.Journal.Path, "journal.jsonl"))
+	importer.reviewQueue = NewReviewQueue(importer.resolvePath(importer.config.Watch.QueuePath, "review.jsonl"))
+	importer.choices = NewChoiceStore(importer.resolvePath(importer.config.Choices.Path, "choices.json"))
 
 	importer.tvShowRegex1 = mapregexp.MustCompile(`(?P<name>.+?)(\.|-|_|\s)+([\(\[]?(?P<year>\d{4})[\)\]]?).*?(\.|-|_|\s)+[sS](?P<season>\d+).*?[eE](?P<episode>\d+)(\.|-|_|\s)*(?P<other>.*?)\s*\.(?P<ext>[^\.]*)$`)
 	importer.tvShowRegex2 = mapregexp.MustCompile(`(?P<name>.+?)(\.|-|_|\s)*[sS](?P<season>\d+).*?[eE](?P<episode>\d+)(\.|-|_|\s)*(?P<other>.*?)\s*\.(?P<ext>[^\.]*)$`)
 	importer.tvShowRegex3 = mapregexp.MustCompile(`(?P<name>.+?)(\.|-|_|\s)+([\(\[]?(?P<year>\d{4})[\)\]]?).*?(\.|-|_|\s)+(?P<season>\d+)[xX]?(?P<episode>\d{2})(\.|-|_|\s)*(?P<other>.*?)\s*\.(?P<ext>[^\.]*)$`)
 	importer.tvShowRegex4 = mapregexp.MustCompile(`(?P<name>.+?)(\.|-|_|\s)*(?P<season>\d+)[xX]?(?P<episode>\d{2})(\.|-|_|\s)*(?P<other>.*?)\s*\.(?P<ext>[^\.]*)$`)
+	importer.dailyShowRegex = mapregexp.MustCompile(`(?P<name>.+?)(\.|-|_|\s)+[\(\[]?(?P<airdate>(19|20)\d{2}(\.|-|_|\s)(0[1-9]|1[0-2])(\.|-|_|\s)(0[1-9]|[12]\d|3[01]))[\)\]]?(\.|-|_|\s)*(?P<other>.*?)\s*\.(?P<ext>[^\.]*)$`)
+	importer.absoluteEpisodeRegex = mapregexp.MustCompile(`^(\[(?P<group>[^\]]+)\]\s*)?(?P<name>.+?)\s+-\s+(?P<absolute>\d{1,4})(v\d+)?(\s+(?P<other>.*?))?\s*\.(?P<ext>[^\.]*)$`)
+	importer.bareEpisodeRegex = mapregexp.MustCompile(`^(([eE]|[eE]pisode|[eE]p)(\.|-|_|\s)*)?(?P<episode>\d{1,3})((\.|-|_|\s)+(?P<other>.*?))?\s*\.(?P<ext>[^\.]*)$`)
+	importer.bareSeasonEpisodeRegex = mapregexp.MustCompile(`^[sS]?(?P<season>\d{1,2})([xX]|[eE])(?P<episode>\d{2,3})((\.|-|_|\s)+(?P<other>.*?))?\s*\.(?P<ext>[^\.]*)$`)
+	importer.directoryRegex = mapregexp.MustCompile(`^(?P<name>.+?)((\.|-|_|\s)+[\(\[]?(?P<year>(19|[2-9]\d)\d{2})[\)\]]?((\.|-|_|\s)+(?P<other>.*?))?)?\s*$`)
+	importer.seasonDirRegex = regexp.MustCompile(`(?i)^((season|series|s)(\.|-|_|\s)*(\d{1,4})|specials?)$`)
+	importer.specialRegex = mapregexp.MustCompile(`(?P<name>.+?)(\.|-|_|\s)+([sS]pecial|SPECIAL|OVA|OAV)(\.|-|_|\s)+(?P<special>.+?)\s*\.(?P<ext>[^\.]*)$`)
 	importer.singleDocumentaryRegex = mapregexp.MustCompile(`(?P<name>.+?)\s*\.(?P<ext>[^\.]*)$`)
 	importer.multiDocumentaryRegex = mapregexp.MustCompile(`(?P<name>.+?)(\.|-|_|\s)*([pP][tT]|part|Part|[eE]|episode|Episode).*?(?P<episode>\d+)\s*\.(?P<ext>[^\.]*)$`)
 	importer.yearDocumentaryRegex = mapregexp.MustCompile(`(?P<name>.+?)(\.|-|_|\s)*((year|Year).*)?(?P<year>(19|[2-9]\d)\d{2}).*?([eE]|episode|Episode|part|Part|pt|PT|Pt).*?(?P<episode>\d+)(\.|-|_|\s)*(?P<other>.*?)\s*\.(?P<ext>[^\.]*)$`)
@@ -143,14 +307,40 @@ func NewNasImporter(configPath string, automaticMode bool) (importer NasImporter
 
 	importer.tvdbWebSearchSeriesRegex = regexp.MustCompile(`(?P<before><a href="/\?tab=series&amp;id=)(?P<seriesId>\d+)(?P<after>\&amp;lid=\d*">)`)
 
+	importer.sampleRegex = regexp.MustCompile(`(?i)(^|[\.\-_\s\[\(])sample([\.\-_\s\]\)]|$)`)
 	importer.wordRegex = regexp.MustCompile("[^\\.\\-_\\+\\s]+")
-	importer.automaticMode = automaticMode
+	importer.compileReleaseRegexes()
+	importer.automaticMode = options.AutomaticMode
+	importer.dryRun = options.DryRun
+	importer.jsonOutput = options.JSONOutput
+	importer.out = os.Stdout
+	importer.reportOut = os.Stdout
+
+	// Keep stdout clean for JSON reports, everything meant for humans goes to stderr instead.
+	if importer.jsonOutput {
+		importer.out = os.Stderr
+	}
+	importer.provider = provider
 
-	importer.ReadExistingMedia()
+	// Indexing a large library takes a while, so it is left to the commands which import, see ReadExistingMedia.
+	importer.library = NewLibrary()
 
 	return
 }
 
+// resolvePath resolves a path from the config relative to the config file, using defaultPath if it is empty.
+func (importer *NasImporter) resolvePath(path, defaultPath string) string {
+	if path == "" {
+		path = defaultPath
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(importer.configPath), path)
+	}
+
+	return path
+}
+
 func (importer *NasImporter) ReadConfig() (err error) {
 	configBytes, err := ioutil.ReadFile(importer.configPath)
 
@@ -158,14 +348,31 @@ func (importer *NasImporter) ReadConfig() (err error) {
 		return
 	}
 
-	err = json.Unmarshal(configBytes, &importer.config)
+	if err = json.Unmarshal(configBytes, &importer.config); err != nil {
+		return
+	}
+
+	if err = importer.compileTemplates(); err != nil {
+		return
+	}
+
+	if err = importer.compilePatterns(); err != nil {
+		return
+	}
+
+	err = importer.compileAliases()
 
 	return
 }
 
-func (importer *NasImporter) ReadExistingMedia() (err error) {
-	_, importer.existingTVShowDirs, err = importer.getFilesDirs(importer.config.MediaDirs.TVDir)
-	importer.existingDocumentaryFiles, importer.existingDocumentaryDirs, err = importer.getFilesDirs(importer.config.MediaDirs.DocumentaryDir)
+func (importer *NasImporter) ClearCache() (err error) {
+	err = importer.cache.Clear()
+
+	return
+}
+
+func (importer *NasImporter) CacheStats() (stats []CacheStats, err error) {
+	stats, err = importer.cache.Stats()
 
 	return
 }
@@ -202,51 +409,42 @@ func (importer *NasImporter) getFilesDirs(path string) (files []string, dirs []s
 	return
 }
 
-func (importer *NasImporter) detectTVShowFields(path string) (tvShowFields map[string]string, err error) {
-	tvRegexes := [...]*mapregexp.MapRegexp{
-		importer.tvShowRegex1,
-		importer.tvShowRegex2,
-		importer.tvShowRegex3,
-		importer.tvShowRegex4,
-	}
-
-	// Try the different tvRegexes.
-	for _, tvRegex := range tvRegexes {
-		tvShowFields = tvRegex.FindStringSubmatchMap(path)
-
-		if tvShowFields == nil {
-			continue
-		}
-
-		return
-	}
-
-	err = errors.New("Not a TV show")
-
-	return
-}
-
-func (importer *NasImporter) detectTVShow(name string) (order ScoreItems, err error) {
+func (importer *NasImporter) detectTVShow(release *ParsedRelease) (order ScoreItems, err error) {
 	// If we get here, we may have a new/existing TV show, but it could also still be a doco.
 	// Split name of tv show into words, and find the most probable results.
-	order = importer.getLevenshteinOrder(importer.existingTVShowDirs, name)
+	order = importer.getSimilarityOrder(importer.library.SeriesNames(TV), release)
 
 	return
 }
 
-func (importer *NasImporter) detectTvdbSeries(name, genre string) (seriesList tvdb.SeriesList, err error) {
+// detectTvdbSeries searches TheTVDB, and IMDb for series TheTVDB can find by IMDb ID. Warnings go to output.
+func (importer *NasImporter) detectTvdbSeries(output io.Writer, name, genre string) (seriesList tvdb.SeriesList, err error) {
 	words := importer.wordRegex.FindAllString(name, -1)
 	probableTitle := strings.Join(words, " ")
 	cacheKey := CacheKey{probableTitle, genre}
-	seriesList, ok := importer.tvdbCache[cacheKey]
 
-	if ok {
+	if importer.cache.Get("tvdb", cacheKey.String(), &seriesList) {
+		return
+	}
+
+	// Search TVDB for results, and IMDb for series TVDB can find by IMDb ID.
+	if seriesList, err = importer.provider.SearchSeries(probableTitle, importer.config.Interface.NumVisibleResults); err != nil {
+		err = fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+
 		return
 	}
 
-	// Search TVDB for results.
-	seriesList, err = tvdb.SearchSeries(probableTitle, importer.config.Interface.NumVisibleResults)
-	rawMovieIMDBResults, _ := imdb.SearchTitle(&importer.imdbClient, probableTitle)
+	// IMDb only adds to what TheTVDB found, so TheTVDB's results are used without it. They aren't cached though, IMDb
+	// may find more next time.
+	rawMovieIMDBResults, imdbErr := importer.provider.SearchTitles(probableTitle)
+	complete := true
+
+	if imdbErr != nil && !errors.Is(imdbErr, ErrNotFound) {
+		fmt.Fprintf(output, "Warning: not searching IMDb for series: %v\n", imdbErr)
+
+		complete = false
+	}
+
 	idMap := make(map[string]struct{})
 	count := 0
 
@@ -257,7 +455,7 @@ func (importer *NasImporter) detectTvdbSeries(name, genre string) (seriesList tv
 			}
 
 			idMap[rawMovieIMDBResult.ID] = struct{}{}
-			series, err := tvdb.GetSeriesByIMDBId(rawMovieIMDBResult.ID)
+			series, err := importer.provider.GetSeriesByIMDBId(rawMovieIMDBResult.ID)
 
 			if err == nil {
 				found := false
@@ -305,71 +503,44 @@ func (importer *NasImporter) detectTvdbSeries(name, genre string) (seriesList tv
 		seriesList = genreSeriesList
 	}
 
-	importer.tvdbCache[cacheKey] = seriesList
-
-	return
-}
-
-func (importer *NasImporter) detectDocumentaryFields(path string) (documentaryFields map[string]string, err error) {
-	documentaryRegexes := [...]*mapregexp.MapRegexp{
-		importer.tvShowRegex1,
-		importer.tvShowRegex2,
-		importer.tvShowRegex3,
-		importer.tvShowRegex4,
-		importer.yearDocumentaryRegex,
-		importer.multiDocumentaryRegex,
-		importer.singleDocumentaryRegex,
-	}
-
-	// Try the different documentaryRegexes in order of complexity as a singleDocumentaryRegex will almost always return results.
-	for _, documentaryRegex := range documentaryRegexes {
-		documentaryFields = documentaryRegex.FindStringSubmatchMap(path)
-
-		if documentaryFields == nil {
-			continue
-		}
-
-		return
+	if complete {
+		importer.setCache(output, "tvdb", cacheKey.String(), seriesList, len(seriesList.Series) == 0)
 	}
 
-	err = errors.New("Not a documentary")
-
 	return
 }
 
-func (importer *NasImporter) detectDocumentary(name string) (order ScoreItems, err error) {
-	order = importer.getLevenshteinOrder(importer.existingDocumentaryDirs, name)
+func (importer *NasImporter) detectDocumentary(release *ParsedRelease) (order ScoreItems, err error) {
+	order = importer.getSimilarityOrder(importer.library.SeriesNames(Documentary), release)
 
 	return
 }
 
-func (importer *NasImporter) detectMovieFields(path string) (movieFields map[string]string, err error) {
-	movieFields = importer.movieWithYearRegex.FindStringSubmatchMap(path)
+// detectIMDBMovie searches IMDb for movies, optionally of a genre or, prefixed with "!", not of it. Warnings go to
+// output.
+func (importer *NasImporter) detectIMDBMovie(output io.Writer, name, genre string) (movieIMDBResults []imdb.Title, err error) {
+	movieWords := importer.wordRegex.FindAllString(name, -1)
+	probableTitle := strings.Join(movieWords, " ")
+	cacheKey := CacheKey{probableTitle, genre}
 
-	if movieFields == nil {
-		movieFields = importer.movieWithoutYearRegex.FindStringSubmatchMap(path)
+	if importer.cache.Get("imdb", cacheKey.String(), &movieIMDBResults) {
+		return
 	}
 
-	if movieFields == nil {
-		err = errors.New("Not a movie")
-	}
+	// Search IMDB for results. Finding nothing is cached like any other result, but failed searches aren't, they may
+	// succeed next time.
+	rawMovieIMDBResults, err := importer.provider.SearchTitles(probableTitle)
 
-	return
-}
+	if errors.Is(err, ErrNotFound) {
+		err = nil
+	} else if err != nil {
+		err = fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
 
-func (importer *NasImporter) detectIMDBMovie(name, genre string) (movieIMDBResults []imdb.Title, err error) {
-	movieWords := importer.wordRegex.FindAllString(name, -1)
-	probableTitle := strings.Join(movieWords, " ")
-	cacheKey := CacheKey{probableTitle, genre}
-	movieIMDBResults, ok := importer.imdbCache[cacheKey]
-
-	if ok {
 		return
 	}
 
-	// Search IMDB for results.
-	// Ignore error, it seems that if no results are found we get an error.
-	rawMovieIMDBResults, _ := imdb.SearchTitle(&importer.imdbClient, probableTitle)
+	complete := true
+
 	idMap := make(map[string]struct{})
 	count := 0
 
@@ -399,13 +570,18 @@ func (importer *NasImporter) detectIMDBMovie(name, genre string) (movieIMDBResul
 		}
 
 		for _, movieIMDBResult := range movieIMDBResults {
-			fullMovieIMDBResult, err := imdb.NewTitle(&importer.imdbClient, movieIMDBResult.ID)
+			fullMovieIMDBResult, err := importer.provider.GetTitle(movieIMDBResult.ID)
 
+			// A title whose genres can't be checked is left out, rather than losing the others.
 			if err != nil {
+				fmt.Fprintf(output, "Warning: skipping %v, its genres couldn't be checked: %v\n", movieIMDBResult.ID, err)
+
+				complete = complete && errors.Is(err, ErrNotFound)
+
 				continue
 			}
 
-			movieIMDBResult = *fullMovieIMDBResult
+			movieIMDBResult = fullMovieIMDBResult
 
 			for _, movieGenre := range movieIMDBResult.Genres {
 				if (strings.ToLower(genre) == strings.ToLower(movieGenre)) != negate {
@@ -419,40 +595,120 @@ func (importer *NasImporter) detectIMDBMovie(name, genre string) (movieIMDBResul
 		movieIMDBResults = genreMovieIMDBResults
 	}
 
-	importer.imdbCache[cacheKey] = movieIMDBResults
+	if complete {
+		importer.setCache(output, "imdb", cacheKey.String(), movieIMDBResults, len(movieIMDBResults) == 0)
+	}
 
 	return
 }
 
-func (importer *NasImporter) getLevenshteinDistance(string1, string2 string) int {
-	string1Words := importer.wordRegex.FindAllString(string1, -1)
-	joinedString1Words := strings.Join(string1Words, " ")
-	string2Words := importer.wordRegex.FindAllString(string2, -1)
-	joinedString2Words := strings.Join(string2Words, " ")
+// setCache stores a lookup result. The cache only saves time, so a result which can't be stored is still used and
+// the failure is a warning for output.
+func (importer *NasImporter) setCache(output io.Writer, bucket, key string, value interface{}, negative bool) {
+	if err := importer.cache.Set(bucket, key, value, negative); err != nil {
+		fmt.Fprintf(output, "Warning: couldn't cache %v lookup: %v\n", bucket, err)
+	}
+}
+
+// getTVDBSeries fetches the full details of a series, which searches leave out.
+func (importer *NasImporter) getTVDBSeries(output io.Writer, id uint64) (series tvdb.Series, err error) {
+	cacheKey := fmt.Sprintf("id|%v", id)
+
+	if importer.cache.Get("tvdb", cacheKey, &series) {
+		return
+	}
+
+	if series, err = importer.provider.GetSeries(id); err != nil {
+		err = fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
 
-	return levenshtein.Distance(strings.ToLower(joinedString1Words), strings.ToLower(joinedString2Words))
+		return
+	}
+
+	importer.setCache(output, "tvdb", cacheKey, series, false)
+
+	return
 }
 
-func (importer *NasImporter) getLevenshteinOrder(candidates []string, target string) (order ScoreItems) {
-	targetWords := importer.wordRegex.FindAllString(target, -1)
-	joinedTargetWords := strings.Join(targetWords, " ")
-	order = make(ScoreItems, len(candidates))
-	orderIndex := 0
+// getIMDBTitle fetches the full details of a title, which searches leave out.
+func (importer *NasImporter) getIMDBTitle(output io.Writer, id string) (title imdb.Title, err error) {
+	cacheKey := "id|" + id
+
+	if importer.cache.Get("imdb", cacheKey, &title) {
+		return
+	}
+
+	if title, err = importer.provider.GetTitle(id); err != nil {
+		err = fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
 
-	for _, candidate := range candidates {
-		candidateWords := importer.wordRegex.FindAllString(candidate, -1)
-		scoreItem := ScoreItem{value: candidate, words: candidateWords}
-		joinedCandidateWords := strings.Join(candidateWords, " ")
-		scoreItem.score = levenshtein.Distance(strings.ToLower(joinedTargetWords), strings.ToLower(joinedCandidateWords))
-		order[orderIndex] = scoreItem
-		orderIndex++
+		return
 	}
 
-	sort.Sort(order)
+	importer.setCache(output, "imdb", cacheKey, title, false)
 
 	return
 }
 
+// sameMatch reports whether two candidates point at the same thing, e.g. a local TV show directory and its series
+// on TheTVDB.
+func sameMatch(scoreItem1, scoreItem2 ScoreItem) bool {
+	if getProviderId(scoreItem1.data) == getProviderId(scoreItem2.data) {
+		return true
+	}
+
+	// Local candidates have no provider ID to compare, go by name instead.
+	isLocal := func(source MediaSource) bool {
+		return source == TVLocal || source == DocumentaryLocal
+	}
+
+	if !isLocal(scoreItem1.source) && !isLocal(scoreItem2.source) {
+		return false
+	}
+
+	return strings.Join(normalizeTitle(scoreItem1.value), " ") == strings.Join(normalizeTitle(scoreItem2.value), " ")
+}
+
+// uncertainty explains why the best match can't be accepted automatically, or returns "" if it can. The best match
+// must clear the configured minimum score and beat the best different candidate by the configured margin.
+func (importer *NasImporter) uncertainty(order ScoreItems) string {
+	minScore := importer.config.Automatic.MinScore
+	minMargin := importer.config.Automatic.MinMargin
+
+	if minScore <= 0 {
+		minScore = defaultMinScore
+	}
+
+	if minMargin <= 0 {
+		minMargin = defaultMinMargin
+	}
+
+	if len(order) == 0 {
+		return "no match"
+	}
+
+	// The same name was matched by hand before, trust that over the scores.
+	if order[0].previouslyChosen {
+		return ""
+	}
+
+	if order[0].score < minScore {
+		return fmt.Sprintf("best score %.2f is below %.2f", order[0].score, minScore)
+	}
+
+	for _, scoreItem := range order[1 :] {
+		if sameMatch(order[0], scoreItem) {
+			continue
+		}
+
+		if order[0].score - scoreItem.score < minMargin {
+			return fmt.Sprintf("ambiguous match, %v scores %.2f against %.2f", scoreItem.value, scoreItem.score, order[0].score)
+		}
+
+		break
+	}
+
+	return ""
+}
+
 func (importer *NasImporter) importMKVUsingMKVMerge(path, outPath string) (err error) {
 	cmd := exec.Command(importer.config.MatroskaMuxers.MKVMerge, "-o", outPath, path)
 	var stdout, stderr bytes.Buffer
@@ -481,57 +737,122 @@ func (importer *NasImporter) importMKVUsingFFMPEG(path, outPath string) (err err
 	return
 }
 
-func (importer *NasImporter) importMKV(path, outPath string) (err error) {
-	if _, err = os.Stat(outPath); !os.IsNotExist(err) {
+func (importer *NasImporter) plannedImportMethod(path string) ImportMethod {
+	if strings.HasSuffix(strings.ToLower(path), ".mkv") {
+		return RenameMethod
+	}
+
+	// mkvmerge is always tried first, ffmpeg is only used if it is missing or fails. It may be a path or a command
+	// found on PATH.
+	if _, err := exec.LookPath(importer.config.MatroskaMuxers.MKVMerge); err == nil {
+		return MKVMergeMethod
+	}
+
+	return FFMPEGMethod
+}
+
+func getProviderId(data interface{}) string {
+	switch data.(type) {
+		case tvdb.Series:
+			return fmt.Sprintf("tvdb:%v", data.(tvdb.Series).Id)
+
+		case imdb.Title:
+			return fmt.Sprintf("imdb:%v", data.(imdb.Title).ID)
+
+		case string:
+			return fmt.Sprintf("local:%v", data.(string))
+	}
+
+	return ""
+}
+
+// checkDestination fails with ErrDestinationExists if outPath is taken. Other errors, such as permission denied, are
+// passed on as they are.
+func checkDestination(outPath string) (err error) {
+	if _, err = os.Stat(outPath); err == nil {
+		return fmt.Errorf("%w: %v", ErrDestinationExists, outPath)
+	} else if os.IsNotExist(err) {
+		return nil
+	}
+
+	return
+}
+
+func (importer *NasImporter) importMKV(path, outPath, providerId string) (method ImportMethod, err error) {
+	if err = checkDestination(outPath); err != nil {
+		// A dry run plans to skip what is already there, only a real import fails on it.
+		if importer.dryRun && errors.Is(err, ErrDestinationExists) {
+			fmt.Fprintf(importer.out, "Plan: skip %v, %v already exists.\n", path, outPath)
+
+			return SkipMethod, nil
+		}
+
 		return
 	}
 
+	if importer.dryRun {
+		method = importer.plannedImportMethod(path)
+		fmt.Fprintf(importer.out, "Plan: %v %v -> %v\n", method, path, outPath)
+
+		return method, nil
+	}
+
 	err = os.MkdirAll(filepath.Dir(outPath), os.ModeDir | 0755)
 
 	if err != nil {
 		return
 	}
 
+	method = RenameMethod
+
 	if strings.HasSuffix(strings.ToLower(path), ".mkv") {
 		err = os.Rename(path, outPath)
+	} else {
+		method = MKVMergeMethod
+		err = importer.importMKVUsingMKVMerge(path, outPath)
 
 		if err != nil {
-			fmt.Println(err)
-		}
+			fmt.Fprintln(importer.out, err)
 
-		return
-	}
-
-	err = importer.importMKVUsingMKVMerge(path, outPath)
+			method = FFMPEGMethod
+			err = importer.importMKVUsingFFMPEG(path, outPath)
 
-	if err == nil {
-		return 
+			if err != nil {
+				err = fmt.Errorf("%w: %v", ErrMuxFailed, err)
+			}
+		}
 	}
 
-	fmt.Println(err)
-
-	err = importer.importMKVUsingFFMPEG(path, outPath)
-
-	if err == nil {
+	if err != nil {
 		return
 	}
 
-	fmt.Println(err)
+	importer.recordImport(JournalEntry{Source: path, Destination: outPath, Method: method, ProviderId: providerId})
 
 	return
 }
 
+// recordImport journals a finished import. The file is already in place by then, so failing the import would only
+// make a retry find its own destination taken, and a journal which can't be written is just a warning.
+func (importer *NasImporter) recordImport(entry JournalEntry) {
+	if err := importer.journal.Record(entry); err != nil {
+		fmt.Fprintf(importer.out, "Warning: couldn't record import in the journal, undo won't know about it: %v\n", err)
+	}
+}
+
 func (importer *NasImporter) GetTVDBEpisodeName(series *tvdb.Series, seasonNum, episodeNum uint64) (episodeName string, err error) {
-	if series.Seasons == nil {
-		if err = series.GetDetail(); err != nil {
-			return
-		}
+	seasons, err := importer.provider.GetSeasons(series)
+
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+
+		return
 	}
 
-	season, ok := series.Seasons[seasonNum]
+	season, ok := seasons[seasonNum]
 
 	if !ok {
-		err = errors.New(fmt.Sprintf("Season %v doesn't exist on TheTVDB.", seasonNum))
+		err = fmt.Errorf("%w: season %v doesn't exist on TheTVDB.", ErrNoMatch, seasonNum)
 
 		return
 	} else {
@@ -545,7 +866,7 @@ func (importer *NasImporter) GetTVDBEpisodeName(series *tvdb.Series, seasonNum,
 	}
 
 	if episodeName == "" {
-		err = errors.New(fmt.Sprintf("Episode %v doesn't exist on TheTVDB.", episodeNum))
+		err = fmt.Errorf("%w: episode %v doesn't exist on TheTVDB.", ErrNoMatch, episodeNum)
 
 		return
 	}
@@ -553,26 +874,227 @@ func (importer *NasImporter) GetTVDBEpisodeName(series *tvdb.Series, seasonNum,
 	return
 }
 
-func (importer *NasImporter) importTV(path string, fileFields map[string]string, data interface{}) (err error) {
-	seriesName := ""
-	episodeName := ""
-	seasonNum, err := strconv.ParseUint(fileFields["season"], 10, 64)
+// GetTVDBEpisodeByAirDate finds the episode of a daily show which first aired on airDate (YYYY-MM-DD).
+func (importer *NasImporter) GetTVDBEpisodeByAirDate(series *tvdb.Series, airDate string) (seasonNum, episodeNum uint64, episodeName string, err error) {
+	seasons, err := importer.provider.GetSeasons(series)
+
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+
+		return
+	}
+
+	found := false
+
+	for season, episodes := range seasons {
+		for _, episode := range episodes {
+			if episode.FirstAired != airDate {
+				continue
+			}
+
+			// Specials sometimes share an air date with a regular episode, prefer the latter.
+			if found && (season == 0 || (seasonNum != 0 && season > seasonNum)) {
+				continue
+			}
+
+			found = true
+			seasonNum = season
+			episodeNum = episode.EpisodeNumber
+			episodeName = episode.EpisodeName
+		}
+	}
+
+	if !found {
+		err = fmt.Errorf("%w: no episode aired on %v on TheTVDB.", ErrNoMatch, airDate)
+	}
+
+	return
+}
+
+// GetTVDBEpisodeByAbsoluteNumber maps an absolute episode number, as used by anime releases, to a season and episode.
+// Series without absolute numbers on TheTVDB are numbered sequentially through their regular seasons.
+func (importer *NasImporter) GetTVDBEpisodeByAbsoluteNumber(series *tvdb.Series, absoluteNum uint64) (seasonNum, episodeNum uint64, episodeName string, err error) {
+	seasons, err := importer.provider.GetSeasons(series)
 
 	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+
+		return
+	}
+
+	seasonNums := []uint64{}
+
+	for season := range seasons {
+		if season != 0 {
+			seasonNums = append(seasonNums, season)
+		}
+	}
+
+	sort.Slice(seasonNums, func(i, j int) bool {
+		return seasonNums[i] < seasonNums[j]
+	})
+
+	sequentialNum := uint64(0)
+	var sequentialEpisode *tvdb.Episode
+	var sequentialSeason uint64
+
+	for _, season := range seasonNums {
+		episodes := append([]*tvdb.Episode{}, seasons[season]...)
+
+		sort.Slice(episodes, func(i, j int) bool {
+			return episodes[i].EpisodeNumber < episodes[j].EpisodeNumber
+		})
+
+		for _, episode := range episodes {
+			if absolute, err := strconv.ParseUint(episode.AbsoluteNumber, 10, 64); err == nil && absolute == absoluteNum {
+				return season, episode.EpisodeNumber, episode.EpisodeName, nil
+			}
+
+			sequentialNum++
+
+			if sequentialNum == absoluteNum {
+				sequentialEpisode = episode
+				sequentialSeason = season
+			}
+		}
+	}
+
+	if sequentialEpisode == nil {
+		err = fmt.Errorf("%w: absolute episode %v doesn't exist on TheTVDB.", ErrNoMatch, absoluteNum)
+
 		return
 	}
 
-	episodeNum, err := strconv.ParseUint(fileFields["episode"], 10, 64)
+	return sequentialSeason, sequentialEpisode.EpisodeNumber, sequentialEpisode.EpisodeName, nil
+}
+
+// GetTVDBSpecialByTitle finds the special in season 0 whose name best matches title, for specials released without
+// an episode number, e.g. "Show.Special.Christmas.mkv".
+func (importer *NasImporter) GetTVDBSpecialByTitle(series *tvdb.Series, title string) (seasonNum, episodeNum uint64, episodeName string, err error) {
+	seasons, err := importer.provider.GetSeasons(series)
 
 	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+
 		return
 	}
 
+	titleWords := normalizeTitle(title)
+	bestSharedWords := 0
+	bestScore := 0.0
+
+	// Special names are often longer than the file name, so prefer the most words found before the closest name.
+	for _, episode := range seasons[0] {
+		episodeWords := map[string]bool{}
+
+		for _, word := range normalizeTitle(episode.EpisodeName) {
+			episodeWords[word] = true
+		}
+
+		sharedWords := 0
+
+		for _, word := range titleWords {
+			if episodeWords[word] {
+				sharedWords++
+			}
+		}
+
+		score := TitleSimilarity(title, 0, episode.EpisodeName, 0)
+
+		if sharedWords == 0 || sharedWords < bestSharedWords || (sharedWords == bestSharedWords && score <= bestScore) {
+			continue
+		}
+
+		bestSharedWords = sharedWords
+		bestScore = score
+		episodeNum = episode.EpisodeNumber
+		episodeName = episode.EpisodeName
+	}
+
+	if bestSharedWords == 0 {
+		err = fmt.Errorf("%w: no special named like %q on TheTVDB.", ErrNoMatch, title)
+	}
+
+	return
+}
+
+// resolveTVDBEpisode finds the season, episode and title for a release, which may be numbered by season and
+// episode, by air date or by absolute episode number.
+func (importer *NasImporter) resolveTVDBEpisode(series *tvdb.Series, release *ParsedRelease, seasonNum uint64) (uint64, uint64, string, error) {
+	switch {
+		case release.HasEpisode():
+			episodeName, err := importer.GetTVDBEpisodeNames(series, seasonNum, release.Episodes)
+
+			return seasonNum, release.Episode(), episodeName, err
+
+		// Daily shows are named by air date, TheTVDB knows which episode aired that day.
+		case release.AirDate != "":
+			return importer.GetTVDBEpisodeByAirDate(series, release.AirDate)
+
+		case release.AbsoluteEpisode != 0:
+			return importer.GetTVDBEpisodeByAbsoluteNumber(series, release.AbsoluteEpisode)
+
+		case release.SpecialTitle != "":
+			return importer.GetTVDBSpecialByTitle(series, release.SpecialTitle)
+	}
+
+	return 0, 0, "", fmt.Errorf("%w: no season/episode number detected.", ErrNoMatch)
+}
+
+// GetTVDBEpisodeNames joins the names of every episode in a multi-episode file.
+func (importer *NasImporter) GetTVDBEpisodeNames(series *tvdb.Series, seasonNum uint64, episodeNums []uint64) (episodeNames string, err error) {
+	names := []string{}
+
+	for _, episodeNum := range episodeNums {
+		episodeName, err := importer.GetTVDBEpisodeName(series, seasonNum, episodeNum)
+
+		if err != nil {
+			return "", err
+		}
+
+		names = append(names, episodeName)
+	}
+
+	episodeNames = strings.Join(names, " & ")
+
+	return
+}
+
+// getPathFields fills in the template placeholders known from the file name and the provider data.
+func (importer *NasImporter) getPathFields(release *ParsedRelease, data interface{}) (fields PathFields) {
+	fields.Year = release.Year
+	fields.Edition = release.Edition
+
+	switch data.(type) {
+		case tvdb.Series:
+			series := data.(tvdb.Series)
+			fields.TVDBId = series.Id
+
+			if fields.Year == 0 && len(series.FirstAired) >= 4 {
+				fields.Year, _ = strconv.ParseUint(series.FirstAired[: 4], 10, 64)
+			}
+
+		case imdb.Title:
+			title := data.(imdb.Title)
+			fields.IMDBId = title.ID
+			fields.Year = uint64(title.Year)
+	}
+
+	return
+}
+
+func (importer *NasImporter) tvDestination(release *ParsedRelease, data interface{}) (outPath string, err error) {
+	seriesName := ""
+	episodeName := ""
+	seasonNum := release.Season
+	episodeNum := release.Episode()
+
 	switch data.(type) {
 		case tvdb.Series:
 			series := data.(tvdb.Series)
 			seriesName = series.SeriesName
-			episodeName, err = importer.GetTVDBEpisodeName(&series, seasonNum, episodeNum)
+
+			seasonNum, episodeNum, episodeName, err = importer.resolveTVDBEpisode(&series, release, seasonNum)
 
 			if err != nil {
 				return
@@ -580,101 +1102,106 @@ func (importer *NasImporter) importTV(path string, fileFields map[string]string,
 
 		case string:
 			seriesName = data.(string)
-	}
 
-	// Replace ASCII slash with unicode division slash in file name parts.
-	seriesName = strings.Replace(seriesName, "/", "∕", -1)
-	episodeName = strings.Replace(episodeName, "/", "∕", -1)
+			if !release.HasEpisode() {
+				err = fmt.Errorf("%w: %v has no season/episode number, air dates, absolute numbers and specials can only be matched on TheTVDB.", ErrNoMatch, seriesName)
 
-	if episodeName != "" {
-		episodeName = " - " + episodeName
+				return
+			}
 	}
 
-	outPath := fmt.Sprintf("%s/%s/Season %02d/%s S%02dE%02d%s.mkv", importer.config.MediaDirs.TVDir, seriesName, seasonNum, seriesName, seasonNum, episodeNum, episodeName)
-
-	err = importer.importMKV(path, outPath)
+	fields := importer.getPathFields(release, data)
+	fields.Series = seriesName
+	fields.Season = seasonNum
+	fields.Episode = episodeNum
+	fields.LastEpisode = release.LastEpisode()
+	fields.AirDate = release.AirDate
+	fields.EpisodeTitle = episodeName
+	outPath, err = importer.buildPath(importer.tvEpisodeTemplate, importer.config.MediaDirs.TVDir, fields)
 
 	return
 }
 
-func (importer *NasImporter) importDocumentary(path string, fileFields map[string]string, data interface{}) (err error) {
-	outPath := ""
+func (importer *NasImporter) documentaryDestination(release *ParsedRelease, data interface{}) (outPath string, err error) {
+	var pathTemplate *template.Template
+	fields := importer.getPathFields(release, data)
 	seriesName := ""
 	episodeName := ""
-	hasSeasonAndEpisode := true
-	hasYear := true
 
 	// This documentary may or may not have season/episode numbers.
-	seasonNum, err := strconv.ParseUint(fileFields["season"], 10, 64)
+	seasonNum := release.Season
+	episodeNum := release.Episode()
+	hasSeasonAndEpisode := release.HasEpisode()
+	year := release.Year
+	hasYear := year != 0
 
-	if err != nil {
+	if !release.HasSeason {
 		seasonNum = 1
 	}
 
-	episodeNum, err := strconv.ParseUint(fileFields["episode"], 10, 64)
-
-	if err != nil {
-		hasSeasonAndEpisode = false
-	}
-
-	year, err := strconv.ParseUint(fileFields["year"], 10, 64)
-
-	if err != nil {
-		hasYear = false
-	}
-
 	switch data.(type) {
 		case tvdb.Series:
 			series := data.(tvdb.Series)
 			seriesName = series.SeriesName
 
-			if !hasSeasonAndEpisode {
+			if !release.IdentifiesEpisode() {
 				err = errors.New("Documentary found on TheTVDB, but no detected season/episode number.")
-	
+
 				return
 			}
 
-			episodeName, err = importer.GetTVDBEpisodeName(&series, seasonNum, episodeNum)
+			seasonNum, episodeNum, episodeName, err = importer.resolveTVDBEpisode(&series, release, seasonNum)
 
 			if err != nil {
 				return
 			}
 
-			seriesName = strings.Replace(seriesName, "/", "∕", -1)
-			episodeName = strings.Replace(episodeName, "/", "∕", -1)
-
-			if episodeName != "" {
-				episodeName = " - " + episodeName
-			}
-
-			outPath = fmt.Sprintf("%s/%s/Season %02d/%s S%02dE%02d%s.mkv", importer.config.MediaDirs.DocumentaryDir, seriesName, seasonNum, seriesName, seasonNum, episodeNum, episodeName)
+			fields.Series = seriesName
+			fields.Season = seasonNum
+			fields.Episode = episodeNum
+			fields.LastEpisode = release.LastEpisode()
+			fields.AirDate = release.AirDate
+			fields.EpisodeTitle = episodeName
+			pathTemplate = importer.documentarySeriesTemplate
 
 		case imdb.Title:
 			title := data.(imdb.Title)
 
 			// FIXME: IMDb titles may have seasons/episodes - not supported by current IMDb lib.
-			outPath = fmt.Sprintf("%v/%v (%v).mkv", importer.config.MediaDirs.DocumentaryDir, title.Name, title.Year)
+			fields.Title = title.Name
+			pathTemplate = importer.documentaryWithYearTemplate
 
 		case string:
 			seriesName := data.(string)
 
 			if hasSeasonAndEpisode {
-				seriesName = strings.Replace(seriesName, "/", "∕", -1)
-				outPath = fmt.Sprintf("%s/%s/Season %02d/%s S%02dE%02d.mkv", importer.config.MediaDirs.DocumentaryDir, seriesName, seasonNum, seriesName, seasonNum, episodeNum)
+				fields.Series = seriesName
+				fields.Season = seasonNum
+				fields.Episode = episodeNum
+				fields.LastEpisode = release.LastEpisode()
+				pathTemplate = importer.documentarySeriesTemplate
 			} else if hasYear {
-				outPath = fmt.Sprintf("%v/%v (%v).mkv", importer.config.MediaDirs.DocumentaryDir, seriesName, year)
+				fields.Title = seriesName
+				fields.Year = year
+				pathTemplate = importer.documentaryWithYearTemplate
 			} else {
-				outPath = fmt.Sprintf("%v/%v.mkv", importer.config.MediaDirs.DocumentaryDir, seriesName)
+				fields.Title = seriesName
+				pathTemplate = importer.documentaryTemplate
 			}
 	}
 
-	fmt.Println(outPath)
-	//err = importer.importMKV(path, outPath)
+	if pathTemplate == nil {
+		err = errors.New("Unable to import documentary, invalid data type.")
+
+		return
+	}
+
+	outPath, err = importer.buildPath(pathTemplate, importer.config.MediaDirs.DocumentaryDir, fields)
 
 	return
 }
 
-func (importer *NasImporter) importMovie(path string, fileFields map[string]string, data interface{}) (err error) {
+func (importer *NasImporter) movieDestination(release *ParsedRelease, data interface{}) (outPath string, err error) {
 	movie, ok := data.(imdb.Title)
 
 	if !ok {
@@ -683,134 +1210,270 @@ func (importer *NasImporter) importMovie(path string, fileFields map[string]stri
 		return
 	}
 
-	outPath := fmt.Sprintf("%v/%v (%v).mkv", importer.config.MediaDirs.MovieDir, movie.Name, movie.Year)
+	fields := importer.getPathFields(release, data)
+	fields.Title = movie.Name
+	outPath, err = importer.buildPath(importer.movieTemplate, importer.config.MediaDirs.MovieDir, fields)
 
-	err = importer.importMKV(path, outPath)
+	return
+}
+
+// isIgnoredName reports whether a file or directory is hidden or a sample.
+func (importer *NasImporter) isIgnoredName(name string) bool {
+	return strings.HasPrefix(name, ".") || importer.sampleRegex.MatchString(name)
+}
+
+func (importer *NasImporter) isVideoFile(name string) bool {
+	return !importer.isIgnoredName(name) && videoExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+func (importer *NasImporter) findVideoFiles(root string) (paths []string, err error) {
+	return importer.walkVideoFiles(root, nil)
+}
+
+// walkVideoFiles finds the video files under root. Errors reading part of the tree stop the walk, unless onError
+// is given and returns nil, which skips that part instead.
+func (importer *NasImporter) walkVideoFiles(root string, onError func(path string, err error) error) (paths []string, err error) {
+	err = filepath.Walk(root, func(path string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			if onError == nil {
+				return err
+			}
+
+			if err = onError(path, err); err == nil && fileInfo != nil && fileInfo.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return err
+		}
+
+		if fileInfo.IsDir() {
+			if path != root && importer.isIgnoredName(fileInfo.Name()) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if fileInfo.Mode().IsRegular() && importer.isVideoFile(fileInfo.Name()) {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
 
 	return
 }
 
-func (importer *NasImporter) Import(path string) (err error) {
-	path, err = filepath.Abs(path)
+// expandPaths lists the files to import, descending into directories to find video files.
+// Paths which can't be imported are returned with their error already set.
+func (importer *NasImporter) expandPaths(paths []string) (files []ImportResult) {
+	for _, path := range paths {
+		path, err := filepath.Abs(path)
 
-	if err != nil {
-		return
+		if err != nil {
+			files = append(files, ImportResult{Path: path, Err: err})
+
+			continue
+		}
+
+		fileInfo, err := os.Stat(path)
+
+		if err != nil {
+			files = append(files, ImportResult{Path: path, Err: err})
+
+			continue
+		}
+
+		if !fileInfo.IsDir() {
+			files = append(files, ImportResult{Path: path})
+
+			continue
+		}
+
+		videoPaths, err := importer.findVideoFiles(path)
+
+		if err != nil {
+			files = append(files, ImportResult{Path: path, Err: err})
+
+			continue
+		}
+
+		if len(videoPaths) == 0 {
+			files = append(files, ImportResult{Path: path, Err: errors.New(fmt.Sprintf("No video files found in %v.", path))})
+
+			continue
+		}
+
+		for _, videoPath := range videoPaths {
+			files = append(files, ImportResult{Path: videoPath})
+		}
 	}
 
-	fileInfo, err := os.Stat(path)
+	return
+}
 
-	if os.IsNotExist(err) {
-		return
+// Import imports each path, descending into directories to find video files, and returns a result per file.
+// Files are looked up by a pool of workers ahead of time, but prompts and file moves happen in order.
+func (importer *NasImporter) Import(paths ...string) (results []ImportResult) {
+	results = importer.expandPaths(paths)
+	lookups := make([]chan *fileLookup, len(results))
+	jobs := make(chan int, len(results))
+
+	// Later parts of a multi-part movie are imported along with the first. They are looked up too, in case the first
+	// isn't matched as a movie and each part is imported on its own.
+	partSets, partFollowers := importer.groupParts(results)
+
+	for index, result := range results {
+		if result.Err == nil {
+			lookups[index] = make(chan *fileLookup, 1)
+			jobs <- index
+		}
 	}
 
-	if fileInfo.IsDir() {
-		err = errors.New(fmt.Sprintf("%v is a directory.", path))
+	close(jobs)
 
-		return
+	numWorkers := importer.config.Interface.LookupWorkers
+
+	if numWorkers < 1 {
+		numWorkers = 4
+	}
+
+	for worker := 0; worker < numWorkers; worker++ {
+		go func() {
+			for index := range jobs {
+				lookups[index] <- importer.lookupFile(results[index].Path, partSets[results[index].Path])
+			}
+		}()
 	}
 
-	file := filepath.Base(path)
+	partErrors := map[string]error{}
+	joinedParts := map[string]bool{}
 
-	fmt.Printf("Importing %s\n", path)
-	fmt.Printf("Attempting to detect if this is a TV show...\n")
+	for index := range results {
+		path := results[index].Path
 
-	tvShowFields, err := importer.detectTVShowFields(file)
+		// A part joined to the first shares its outcome, parts which weren't are imported one by one.
+		if firstPart, ok := partFollowers[path]; ok && joinedParts[firstPart] {
+			results[index].Err = partErrors[firstPart]
+
+			if importer.jsonOutput {
+				importer.writeReport(FileReport{Path: path, Candidates: []Candidate{}}, results[index].Err)
+			}
+		} else if lookups[index] != nil {
+			lookup := <-lookups[index]
+			results[index].Err = importer.importLookup(lookup)
+			partErrors[path] = results[index].Err
+			joinedParts[path] = lookup.joined
+		} else if importer.jsonOutput {
+			importer.writeReport(FileReport{Path: path, Candidates: []Candidate{}}, results[index].Err)
+		}
+	}
+
+	return
+}
+
+func (importer *NasImporter) detectFile(lookup *fileLookup) (err error) {
+	path := lookup.path
+
+	if found, err := importer.detectAlias(lookup); found || err != nil {
+		return err
+	}
+
+	fmt.Fprintf(&lookup.output, "Importing %s\n", path)
+	fmt.Fprintf(&lookup.output, "Attempting to detect if this is a TV show...\n")
+
+	// IMDb being down only fails the file if nothing else matched it.
+	var unavailableErr error
+
+	tvShowRelease, err := importer.ParsePath(path, TV)
 	tvShowOrder := ScoreItems{}
 	tvShowTVDBResults := tvdb.SeriesList{}
 
 	if err == nil {
-		fmt.Printf("TV show fields: %v\n", tvShowFields)
+		fmt.Fprintf(&lookup.output, "TV show fields: %v\n", tvShowRelease)
 
-		tvShowOrder, err = importer.detectTVShow(tvShowFields["name"])
+		tvShowOrder, err = importer.detectTVShow(tvShowRelease)
 
 		if err != nil {
-			log.Fatal(err)
+			return
 		}
 
-		tvShowTVDBResults, err = importer.detectTvdbSeries(tvShowFields["name"], "")
+		tvShowTVDBResults, err = importer.detectTvdbSeries(&lookup.output, tvShowRelease.Title, "")
 
 		if err != nil {
-			log.Fatal(err)
+			return
 		}
 	} else {
-		fmt.Println(err)
+		fmt.Fprintln(&lookup.output, err)
 	}
 
-	fmt.Printf("Attempting to detect if this is a documentary...\n")
+	fmt.Fprintf(&lookup.output, "Attempting to detect if this is a documentary...\n")
 
-	documentaryFields, err := importer.detectDocumentaryFields(file)
+	documentaryRelease, err := importer.ParsePath(path, Documentary)
 	documentaryOrder := ScoreItems{}
 	documentaryTVDBResults := tvdb.SeriesList{}
 	documentaryIMDBResults := []imdb.Title{}
 
 	if err == nil {
-		fmt.Printf("Documentary fields: %v\n", documentaryFields)
+		fmt.Fprintf(&lookup.output, "Documentary fields: %v\n", documentaryRelease)
 
-		documentaryOrder, err = importer.detectDocumentary(documentaryFields["name"])
+		documentaryOrder, err = importer.detectDocumentary(documentaryRelease)
 
 		if err != nil {
-			log.Fatal(err)
+			return
 		}
 
-		hasSeasonAndEpisode := true
-
 		// This documentary may or may not have season/episode numbers.
-		_, err := strconv.ParseUint(documentaryFields["episode"], 10, 64)
-
-		if err != nil {
-			hasSeasonAndEpisode = false
-		}
-
-		if hasSeasonAndEpisode {
-			documentaryTVDBResults, err = importer.detectTvdbSeries(documentaryFields["name"], "documentary")
+		if documentaryRelease.IdentifiesEpisode() {
+			documentaryTVDBResults, err = importer.detectTvdbSeries(&lookup.output, documentaryRelease.Title, "documentary")
 
 			if err != nil {
-				log.Fatal(err)
+				return
 			}
 		}
 
-		documentaryIMDBResults, err = importer.detectIMDBMovie(documentaryFields["name"], "documentary")
+		documentaryIMDBResults, err = importer.detectIMDBMovie(&lookup.output, documentaryRelease.Title, "documentary")
 
-		if err != nil {
-			log.Fatal(err)
+		if errors.Is(err, ErrProviderUnavailable) {
+			fmt.Fprintf(&lookup.output, "Warning: not searching IMDb for documentaries: %v\n", err)
+
+			unavailableErr = err
+		} else if err != nil {
+			return
 		}
 	} else {
-		fmt.Println(err)
+		fmt.Fprintln(&lookup.output, err)
 	}
 
-	fmt.Printf("Attempting to detect if this is a movie...\n")
+	fmt.Fprintf(&lookup.output, "Attempting to detect if this is a movie...\n")
 
-	movieFields, err := importer.detectMovieFields(file)
+	movieRelease, err := importer.ParsePath(path, Movie)
 	movieIMDBResults := []imdb.Title{}
 
 	if err == nil {
-		fmt.Printf("Movie fields: %v\n", movieFields)
+		fmt.Fprintf(&lookup.output, "Movie fields: %v\n", movieRelease)
 
-		movieName := movieFields["name"]
+		// If we have a year, use it to aid our search.
+		movieIMDBResults, err = importer.detectIMDBMovie(&lookup.output, movieRelease.SearchName(), "")
 
-		// If we have a year, use it to aid our search.z
-		movieYear, ok := movieFields["year"]
+		if errors.Is(err, ErrProviderUnavailable) {
+			fmt.Fprintf(&lookup.output, "Warning: not searching IMDb for movies: %v\n", err)
 
-		if ok {
-			movieName += " (" + movieYear + ")"
-		}
-
-		movieIMDBResults, err = importer.detectIMDBMovie(movieName, "")
-
-		if err != nil {
-			log.Fatal(err)
+			unavailableErr = err
+		} else if err != nil {
+			return
 		}
 	} else {
-		fmt.Println(err)
+		fmt.Fprintln(&lookup.output, err)
 	}
 
-	fmt.Printf("Most likely TV show matches (local):\n")
+	fmt.Fprintf(&lookup.output, "Most likely TV show matches (local):\n")
 
 	absoluteOrder := ScoreItems{}
 
 	for index, tvShow := range tvShowOrder {
 		if index < importer.config.Interface.NumVisibleResults {
-			fmt.Printf("\t%v\n", tvShow.value)
+			fmt.Fprintf(&lookup.output, "\t%v\n", tvShow.value)
 		}
 
 		tvShow.source = TVLocal
@@ -818,30 +1481,23 @@ func (importer *NasImporter) Import(path string) (err error) {
 		absoluteOrder = append(absoluteOrder, tvShow)
 	}
 
-	fmt.Printf("\nMost likely TV show matches (TheTVDB):\n")
+	fmt.Fprintf(&lookup.output, "\nMost likely TV show matches (TheTVDB):\n")
 
 	for index, tvShowTVDBResult := range tvShowTVDBResults.Series {
-		score := 0
-
-		if year, ok := tvShowFields["year"]; ok {
-			score = importer.getLevenshteinDistance(fmt.Sprintf(tvShowFields["name"] + " (%v)", year), fmt.Sprintf(tvShowTVDBResult.SeriesName))
-		} else {
-			score = importer.getLevenshteinDistance(tvShowFields["name"], tvShowTVDBResult.SeriesName)
-		}
-
+		score := TitleSimilarity(tvShowRelease.Title, tvShowRelease.Year, tvShowTVDBResult.SeriesName, firstAiredYear(tvShowTVDBResult.FirstAired))
 		scoreItem := ScoreItem{value: tvShowTVDBResult.SeriesName, score: score, source: TVTVDB, data: tvShowTVDBResult}
 		absoluteOrder = append(absoluteOrder, scoreItem)
 
 		if index < importer.config.Interface.NumVisibleResults {
-			fmt.Printf("\t%v\n", tvShowTVDBResult.SeriesName)
+			fmt.Fprintf(&lookup.output, "\t%v\n", tvShowTVDBResult.SeriesName)
 		}
 	}
 
-	fmt.Printf("\nMost likely documentary matches (local):\n")
+	fmt.Fprintf(&lookup.output, "\nMost likely documentary matches (local):\n")
 
 	for index, documentary := range documentaryOrder {
 		if index < importer.config.Interface.NumVisibleResults {
-			fmt.Printf("\t%v\n", documentary.value)
+			fmt.Fprintf(&lookup.output, "\t%v\n", documentary.value)
 		}
 
 		documentary.source = DocumentaryLocal
@@ -849,59 +1505,96 @@ func (importer *NasImporter) Import(path string) (err error) {
 		absoluteOrder = append(absoluteOrder, documentary)
 	}
 
-	fmt.Printf("\nMost likely documentary matches (TheTVDB):\n")
+	fmt.Fprintf(&lookup.output, "\nMost likely documentary matches (TheTVDB):\n")
 
 	for index, documentaryTVDBResult := range documentaryTVDBResults.Series {
-		score := importer.getLevenshteinDistance(documentaryFields["name"], documentaryTVDBResult.SeriesName)
+		score := TitleSimilarity(documentaryRelease.Title, documentaryRelease.Year, documentaryTVDBResult.SeriesName, firstAiredYear(documentaryTVDBResult.FirstAired))
 		scoreItem := ScoreItem{value: documentaryTVDBResult.SeriesName, score: score, source: DocumentaryTVDB, data: documentaryTVDBResult}
 		absoluteOrder = append(absoluteOrder, scoreItem)
 
 		if index < importer.config.Interface.NumVisibleResults {
-			fmt.Printf("\t%v\n", documentaryTVDBResult.SeriesName)
+			fmt.Fprintf(&lookup.output, "\t%v\n", documentaryTVDBResult.SeriesName)
 		}
 	}
 
-	fmt.Printf("\nMost likely documentary matches (IMDb):\n")
+	fmt.Fprintf(&lookup.output, "\nMost likely documentary matches (IMDb):\n")
 
 	for index, documentaryIMDBResult := range documentaryIMDBResults {
-		score := 0
-
-		if year, ok := documentaryFields["year"]; ok {
-			score = importer.getLevenshteinDistance(fmt.Sprintf(documentaryFields["name"] + " (%v)", year), fmt.Sprintf(documentaryIMDBResult.Name + " (%v)", documentaryIMDBResult.Year))
-		} else {
-			score = importer.getLevenshteinDistance(documentaryFields["name"], documentaryIMDBResult.Name)
-		}
-
+		score := TitleSimilarity(documentaryRelease.Title, documentaryRelease.Year, documentaryIMDBResult.Name, uint64(documentaryIMDBResult.Year))
 		scoreItem := ScoreItem{value: documentaryIMDBResult.Name, score: score, source: DocumentaryIMDB, data: documentaryIMDBResult}
 		absoluteOrder = append(absoluteOrder, scoreItem)
 
 		if index < importer.config.Interface.NumVisibleResults {
-			fmt.Printf("\t%v (%v)\n", documentaryIMDBResult.Name, documentaryIMDBResult.Year)
+			fmt.Fprintf(&lookup.output, "\t%v (%v)\n", documentaryIMDBResult.Name, documentaryIMDBResult.Year)
 		}
 	}
 
-	fmt.Printf("\nMost likely movie matches (IMDb):\n")
+	fmt.Fprintf(&lookup.output, "\nMost likely movie matches (IMDb):\n")
 
 	for index, movieIMDBResult := range movieIMDBResults {
-		score := 0
-
-		if year, ok := movieFields["year"]; ok {
-			score = importer.getLevenshteinDistance(fmt.Sprintf(movieFields["name"] + " (%v)", year), fmt.Sprintf(movieIMDBResult.Name + " (%v)", movieIMDBResult.Year))
-		} else {
-			score = importer.getLevenshteinDistance(movieFields["name"], movieIMDBResult.Name)
-		}
-
+		score := TitleSimilarity(movieRelease.Title, movieRelease.Year, movieIMDBResult.Name, uint64(movieIMDBResult.Year))
 		scoreItem := ScoreItem{value: movieIMDBResult.Name, score: score, source: MovieIMDB, data: movieIMDBResult}
 		absoluteOrder = append(absoluteOrder, scoreItem)
 
 		if index < importer.config.Interface.NumVisibleResults {
-			fmt.Printf("\t%v (%v)\n", movieIMDBResult.Name, movieIMDBResult.Year)
+			fmt.Fprintf(&lookup.output, "\t%v (%v)\n", movieIMDBResult.Name, movieIMDBResult.Year)
 		}
 	}
 
+	if len(absoluteOrder) == 0 && unavailableErr != nil {
+		return unavailableErr
+	}
+
 	sort.Sort(absoluteOrder)
+	lookup.tvShowRelease = tvShowRelease
+	lookup.documentaryRelease = documentaryRelease
+	lookup.movieRelease = movieRelease
+	lookup.absoluteOrder = absoluteOrder
+	importer.scoreRuntimes(lookup)
 
-	fmt.Printf("\nMost likely overall matches:\n")
+	return nil
+}
+
+func (importer *NasImporter) lookupFile(path string, parts []string) (lookup *fileLookup) {
+	lookup = &fileLookup{path: path, parts: parts}
+	lookup.err = importer.detectFile(lookup)
+
+	return
+}
+
+func (importer *NasImporter) importFile(path string) (err error) {
+	err = importer.importLookup(importer.lookupFile(path, nil))
+
+	return
+}
+
+// importLookup shows the candidates found for a file, picks a match and imports the file.
+func (importer *NasImporter) importLookup(lookup *fileLookup) (err error) {
+	importer.out.Write(lookup.output.Bytes())
+
+	// Lookups run ahead, so choices made for earlier files in the batch are only known now.
+	importer.markPreviousChoices(lookup)
+	report := newFileReport(lookup)
+
+	if importer.jsonOutput {
+		defer func() {
+			importer.writeReport(report, err)
+		}()
+	}
+
+	if lookup.err != nil {
+		err = lookup.err
+
+		return
+	}
+
+	path := lookup.path
+	tvShowRelease := lookup.tvShowRelease
+	documentaryRelease := lookup.documentaryRelease
+	movieRelease := lookup.movieRelease
+	absoluteOrder := lookup.absoluteOrder
+
+	fmt.Fprintf(importer.out, "\nMost likely overall matches:\n")
 
 	for index, result := range absoluteOrder {
 		if index < importer.config.Interface.NumVisibleResults {
@@ -926,30 +1619,57 @@ func (importer *NasImporter) Import(path string) (err error) {
 					source = fmt.Sprintf("IMDb movie (ID: %v)", movie.ID)
 			}
 
+			if result.previouslyChosen {
+				source += " [previously chosen]"
+			}
+
 			if result.source == MovieIMDB {
 				movie := result.data.(imdb.Title)
 
-				fmt.Printf("%v \t%v | %v (%v)\n\t\t%v\n", result.score, index + 1, result.value, movie.Year, source)
+				fmt.Fprintf(importer.out, "%.2f \t%v | %v (%v)\n\t\t%v\n", result.score, index + 1, result.value, movie.Year, source)
 			} else {
-				fmt.Printf("%v \t%v | %v\n\t\t%v\n", result.score, index + 1, result.value, source)
+				fmt.Fprintf(importer.out, "%.2f \t%v | %v\n\t\t%v\n", result.score, index + 1, result.value, source)
 			}
 		} else {
 			break
 		}
 	}
 
+	if len(absoluteOrder) == 0 {
+		err = ErrNoMatch
+
+		return
+	}
+
 	matchId := 1
 
-	if importer.automaticMode {
-		fmt.Println("\nAutomatically choosing ID: 1")
+	if lookup.aliased {
+		fmt.Fprintln(importer.out, "\nChoosing aliased ID: 1")
+	} else if importer.automaticMode {
+		// Only take the best match when it is clearly right, everything else waits for a human.
+		if reason := importer.uncertainty(absoluteOrder); reason != "" {
+			if importer.dryRun {
+				fmt.Fprintf(importer.out, "\nPlan: queue for review, %v\n", reason)
+			} else if err = importer.reviewQueue.Add(path, reason); err != nil {
+				return
+			} else {
+				fmt.Fprintf(importer.out, "\nQueued for review, %v\n", reason)
+			}
+
+			err = ErrQueuedForReview
+
+			return
+		}
+
+		fmt.Fprintln(importer.out, "\nAutomatically choosing ID: 1")
 	} else {
 		for {
-			fmt.Printf("\nEnter ID: ")
+			fmt.Fprintf(importer.out, "\nEnter ID: ")
 
 			_, err := fmt.Scanf("%d", &matchId)
 
-			if err != nil || matchId > importer.config.Interface.NumVisibleResults || matchId < 1 {
-				fmt.Printf("\nSorry, invalid ID. Try again.\n")
+			if err != nil || matchId > importer.config.Interface.NumVisibleResults || matchId > len(absoluteOrder) || matchId < 1 {
+				fmt.Fprintf(importer.out, "\nSorry, invalid ID. Try again.\n")
 			} else {
 				break
 			}
@@ -957,25 +1677,60 @@ func (importer *NasImporter) Import(path string) (err error) {
 	}
 
 	match := absoluteOrder[matchId - 1]
+	outPath := ""
+	matchCandidate := newCandidate(matchId, match)
+	report.Match = &matchCandidate
 
 	switch match.source {
 		case TVLocal:
-			err = importer.importTV(path, tvShowFields, match.data)
+			outPath, err = importer.tvDestination(tvShowRelease, match.data)
 
 		case TVTVDB:
-			err = importer.importTV(path, tvShowFields, match.data)
+			outPath, err = importer.tvDestination(tvShowRelease, match.data)
 
 		case DocumentaryLocal:
-			err = importer.importDocumentary(path, documentaryFields, match.data)
+			outPath, err = importer.documentaryDestination(documentaryRelease, match.data)
 
 		case DocumentaryTVDB:
-			err = importer.importDocumentary(path, documentaryFields, match.data)
+			outPath, err = importer.documentaryDestination(documentaryRelease, match.data)
 
 		case DocumentaryIMDB:
-			err = importer.importDocumentary(path, documentaryFields, match.data)
+			outPath, err = importer.documentaryDestination(documentaryRelease, match.data)
 
 		case MovieIMDB:
-			err = importer.importMovie(path, movieFields, match.data)
+			outPath, err = importer.movieDestination(movieRelease, match.data)
+	}
+
+	if err != nil {
+		return
+	}
+
+	report.Destination = outPath
+
+	// Another copy, e.g. in a different format or quality, is worth knowing about but doesn't stop the import.
+	if existing, ok := importer.findExisting(match.source.mediaType(), outPath); ok && existing != outPath {
+		fmt.Fprintf(importer.out, "Warning: already in the library as %v\n", existing)
+
+		report.Existing = existing
+	}
+
+	// Only a movie is joined, other matches such as a documentary series may take each part for an episode.
+	if len(lookup.parts) > 1 && match.source == MovieIMDB {
+		lookup.joined = true
+		report.Method, err = importer.importParts(lookup.parts, outPath, getProviderId(match.data))
+	} else {
+		report.Method, err = importer.importMKV(path, outPath, getProviderId(match.data))
+	}
+
+	if err == nil && !importer.dryRun {
+		importer.indexFile(match.source.mediaType(), outPath)
+	}
+
+	// The file is imported either way, a choice which can't be saved is only worth a warning.
+	if err == nil && !importer.dryRun && !importer.automaticMode && !lookup.aliased {
+		if choiceErr := importer.rememberChoice(lookup, match); choiceErr != nil {
+			fmt.Fprintf(importer.out, "Warning: couldn't remember choice: %v\n", choiceErr)
+		}
 	}
 
 	return
@@ -3,10 +3,22 @@ package nasimporter
 import (
 	"testing"
 	"reflect"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"fmt"
+	"time"
+	"errors"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"github.com/garfunkel/go-tvdb"
+	"github.com/StalkR/imdb"
 )
 
 func setup(t *testing.T) (importer NasImporter) {
-	importer, err := NewNasImporter("config.json", false)
+	importer, err := NewNasImporter("testdata/config.json", Options{}, NewMemoryProvider())
 
 	if err != nil {
 		t.Error(err)
@@ -123,3 +135,1669 @@ func TestTVShowRegex2(t *testing.T) {
 		}
 	}
 }
+
+func TestParseRelease(t *testing.T) {
+	importer := setup(t)
+
+	testReleases := []struct {
+		file string
+		mediaType MediaType
+		release ParsedRelease
+	}{
+		{"Show.Name.S01E02.720p.WEB-DL.DD5.1.H.264-NTb.mkv", TV, ParsedRelease{
+			Title: "Show Name",
+			HasSeason: true,
+			Season: 1,
+			Episodes: []uint64{2},
+			Resolution: "720p",
+			Source: "WEB-DL",
+			VideoCodec: "H.264",
+			AudioCodec: "DD5.1",
+			ReleaseGroup: "NTb",
+			Other: "720p.WEB-DL.DD5.1.H.264-NTb",
+			Ext: "mkv",
+		}},
+		{"Blade.Runner.1982.Final.Cut.1080p.BluRay.x264.mkv", Movie, ParsedRelease{
+			Title: "Blade Runner",
+			Year: 1982,
+			Resolution: "1080p",
+			Source: "BluRay",
+			VideoCodec: "x264",
+			Edition: "Final Cut",
+			Other: "Final.Cut.1080p.BluRay.x264",
+			Ext: "mkv",
+		}},
+		{"Charlotte's.Web.2006.1080p.mkv", Movie, ParsedRelease{
+			Title: "Charlotte's Web",
+			Year: 2006,
+			Resolution: "1080p",
+			Other: "1080p",
+			Ext: "mkv",
+		}},
+		{"Dark.Web.S01E01.mkv", TV, ParsedRelease{
+			Title: "Dark Web",
+			HasSeason: true,
+			Season: 1,
+			Episodes: []uint64{1},
+			Ext: "mkv",
+		}},
+		{"Law.and.Order.Special.Victims.Unit.2x05.mkv", TV, ParsedRelease{
+			Title: "Law and Order Special Victims Unit",
+			HasSeason: true,
+			Season: 2,
+			Episodes: []uint64{5},
+			Ext: "mkv",
+		}},
+		{"Law.and.Order.Special.Victims.Unit.105.mkv", TV, ParsedRelease{
+			Title: "Law and Order Special Victims Unit",
+			HasSeason: true,
+			Season: 1,
+			Episodes: []uint64{5},
+			Ext: "mkv",
+		}},
+	}
+
+	for _, testRelease := range testReleases {
+		release, err := importer.ParseRelease(testRelease.file, testRelease.mediaType)
+
+		if err != nil {
+			t.Errorf("%v: %v", testRelease.file, err)
+
+			continue
+		}
+
+		if !reflect.DeepEqual(*release, testRelease.release) {
+			t.Errorf("Release mismatch:\n%#v\n%#v", testRelease.release, *release)
+		}
+	}
+}
+
+func TestTitleSimilarity(t *testing.T) {
+	for _, titles := range [][2]string{
+		{"The Office", "Office, The"},
+		{"Amelie", "Amélie"},
+		{"Law & Order", "Law and Order"},
+		{"Marvel's Agents of S.H.I.E.L.D.", "marvels agents of s h i e l d"},
+	} {
+		if score := TitleSimilarity(titles[0], 0, titles[1], 0); score != 1 {
+			t.Errorf("Expected %q and %q to be identical, got %v", titles[0], titles[1], score)
+		}
+	}
+
+	// Only an article moved to the end after a comma is dropped.
+	if score := TitleSimilarity("Plan A", 0, "Plan", 0); score == 1 {
+		t.Errorf("Expected \"Plan A\" and \"Plan\" to differ.")
+	}
+
+	if TitleSimilarity("Breaking Bad", 0, "Braking Bad", 0) <= TitleSimilarity("Breaking Bad", 0, "Bad", 0) {
+		t.Errorf("A typo should score higher than a missing word.")
+	}
+
+	if TitleSimilarity("Dune", 2021, "Dune", 2021) <= TitleSimilarity("Dune", 2021, "Dune", 1984) {
+		t.Errorf("Matching years should score higher.")
+	}
+
+	importer := setup(t)
+	order := importer.getSimilarityOrder([]string{"Office", "The Office (2005)", "Officer Down"}, &ParsedRelease{Title: "The Office", Year: 2005})
+
+	if order[0].value != "The Office (2005)" {
+		t.Errorf("Unexpected order: %v", order)
+	}
+}
+
+func setupImport(t *testing.T, options Options) (importer NasImporter, dir string, provider *MemoryProvider) {
+	dir, err := ioutil.TempDir("", "nasimport")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configPath := filepath.Join(dir, "config.json")
+	config := fmt.Sprintf(`{"media_dirs": {"tv": %q, "documentaries": %q, "movies": %q}, "interface": {"num_visible_results": 5}}`,
+		filepath.Join(dir, "TV"), filepath.Join(dir, "Documentaries"), filepath.Join(dir, "Movies"))
+
+	if err = ioutil.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider = NewMemoryProvider()
+	provider.Series = append(provider.Series, tvdb.Series{Id: 1, SeriesName: "Some Show"})
+	provider.Seasons[1] = map[uint64][]*tvdb.Episode{
+		1: []*tvdb.Episode{
+			&tvdb.Episode{EpisodeNumber: 1, EpisodeName: "Pilot"},
+			&tvdb.Episode{EpisodeNumber: 2, EpisodeName: "Second"},
+		},
+	}
+
+	importer, err = NewNasImporter(configPath, options, provider)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func writeTestFile(t *testing.T, path string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportWithMemoryProvider(t *testing.T) {
+	importer, dir, _ := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Some.Show.S01E02.720p.mkv")
+	writeTestFile(t, path)
+	results := importer.Import(path)
+
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	outPath := filepath.Join(dir, "TV", "Some Show", "Season 01", "Some Show S01E02 - Second.mkv")
+
+	if _, err := os.Stat(outPath); err != nil {
+		t.Errorf("Expected imported file at %v: %v", outPath, err)
+	}
+}
+
+func TestMultiEpisode(t *testing.T) {
+	importer, dir, _ := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	testEpisodes := map[string][]uint64{
+		"Show.S01E01E02.mkv": []uint64{1, 2},
+		"Show.S01E01-E03.720p.mkv": []uint64{1, 2, 3},
+		"Show.S01E01-03.mkv": []uint64{1, 2, 3},
+		"Show.S01E01.720p.mkv": []uint64{1},
+		"Show.S01E01-720p.mkv": []uint64{1},
+	}
+
+	for file, episodes := range testEpisodes {
+		release, err := importer.ParseRelease(file, TV)
+
+		if err != nil {
+			t.Errorf("%v: %v", file, err)
+		} else if !reflect.DeepEqual(release.Episodes, episodes) {
+			t.Errorf("%v: expected episodes %v, got %v", file, episodes, release.Episodes)
+		}
+	}
+
+	path := filepath.Join(dir, "Some.Show.S01E01E02.mkv")
+	writeTestFile(t, path)
+
+	if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	outPath := filepath.Join(dir, "TV", "Some Show", "Season 01", "Some Show S01E01-E02 - Pilot & Second.mkv")
+
+	if _, err := os.Stat(outPath); err != nil {
+		t.Errorf("Expected imported file at %v: %v", outPath, err)
+	}
+}
+
+func TestDailyShow(t *testing.T) {
+	importer, dir, provider := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	release, err := importer.ParseRelease("The.Daily.Show.2023.05.04.720p.WEB.h264-GRP.mkv", TV)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if release.Title != "The Daily Show" || release.AirDate != "2023-05-04" || release.HasEpisode() || release.Year != 0 {
+		t.Errorf("Unexpected daily show release: %v", release)
+	}
+
+	provider.Series = append(provider.Series, tvdb.Series{Id: 2, SeriesName: "Late Talk"})
+	provider.Seasons[2] = map[uint64][]*tvdb.Episode{
+		0: []*tvdb.Episode{
+			&tvdb.Episode{EpisodeNumber: 1, EpisodeName: "Special", FirstAired: "2023-05-04"},
+		},
+		28: []*tvdb.Episode{
+			&tvdb.Episode{EpisodeNumber: 44, EpisodeName: "Guest One", FirstAired: "2023-05-03"},
+			&tvdb.Episode{EpisodeNumber: 45, EpisodeName: "Guest Two", FirstAired: "2023-05-04"},
+		},
+	}
+
+	path := filepath.Join(dir, "Late.Talk.2023.05.04.mkv")
+	writeTestFile(t, path)
+
+	if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	outPath := filepath.Join(dir, "TV", "Late Talk", "Season 28", "Late Talk S28E45 (2023-05-04) - Guest Two.mkv")
+
+	if _, err := os.Stat(outPath); err != nil {
+		t.Errorf("Expected imported file at %v: %v", outPath, err)
+	}
+}
+
+func TestAbsoluteEpisode(t *testing.T) {
+	importer, dir, provider := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	release, err := importer.ParseRelease("[Group] Anime Show - 137 [1080p].mkv", TV)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if release.Title != "Anime Show" || release.AbsoluteEpisode != 137 || release.HasEpisode() || release.ReleaseGroup != "Group" || release.Resolution != "1080p" {
+		t.Errorf("Unexpected absolute release: %v", release)
+	}
+
+	// A number which could be a year is taken for one wherever a name with a year is expected.
+	for _, file := range []string{"Planet Earth - 2006.mkv", "Title - 2049.mkv"} {
+		for _, mediaType := range []MediaType{Documentary, Movie} {
+			release, err := importer.ParseRelease(file, mediaType)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if release.AbsoluteEpisode != 0 || release.Year == 0 || strings.Contains(release.Title, " - ") {
+				t.Errorf("Unexpected %v release for %v: %v", mediaType, file, release)
+			}
+		}
+
+		if release, err := importer.ParseRelease(file, TV); err == nil {
+			t.Errorf("Unexpected TV release for %v: %v", file, release)
+		}
+	}
+
+	provider.Series = append(provider.Series, tvdb.Series{Id: 3, SeriesName: "Anime Show"}, tvdb.Series{Id: 4, SeriesName: "Other Anime"})
+	provider.Seasons[3] = map[uint64][]*tvdb.Episode{
+		1: []*tvdb.Episode{
+			&tvdb.Episode{EpisodeNumber: 1, EpisodeName: "First", AbsoluteNumber: "1"},
+			&tvdb.Episode{EpisodeNumber: 2, EpisodeName: "Second", AbsoluteNumber: "2"},
+		},
+		2: []*tvdb.Episode{
+			&tvdb.Episode{EpisodeNumber: 1, EpisodeName: "Third", AbsoluteNumber: "137"},
+		},
+	}
+
+	// Without absolute numbers on TheTVDB, episodes are counted through the seasons in order, skipping specials.
+	provider.Seasons[4] = map[uint64][]*tvdb.Episode{
+		0: []*tvdb.Episode{
+			&tvdb.Episode{EpisodeNumber: 1, EpisodeName: "Special"},
+		},
+		2: []*tvdb.Episode{
+			&tvdb.Episode{EpisodeNumber: 2, EpisodeName: "Fourth"},
+			&tvdb.Episode{EpisodeNumber: 1, EpisodeName: "Third"},
+		},
+		1: []*tvdb.Episode{
+			&tvdb.Episode{EpisodeNumber: 1, EpisodeName: "First"},
+			&tvdb.Episode{EpisodeNumber: 2, EpisodeName: "Second"},
+		},
+	}
+
+	testPaths := map[string]string{
+		"[Group] Anime Show - 137 [1080p].mkv": filepath.Join("Anime Show", "Season 02", "Anime Show S02E01 - Third.mkv"),
+		"Other Anime - 04.mkv": filepath.Join("Other Anime", "Season 02", "Other Anime S02E02 - Fourth.mkv"),
+	}
+
+	for file, outFile := range testPaths {
+		path := filepath.Join(dir, file)
+		writeTestFile(t, path)
+
+		if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+			t.Fatalf("Unexpected import results: %#v", results)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "TV", outFile)); err != nil {
+			t.Errorf("Expected imported file at %v: %v", outFile, err)
+		}
+	}
+}
+
+func TestSpecials(t *testing.T) {
+	importer, dir, provider := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	release, err := importer.ParseRelease("Some.Show.Special.Christmas.720p.HDTV-GRP.mkv", TV)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if release.Title != "Some Show" || !release.HasSeason || release.Season != 0 || release.SpecialTitle != "Christmas" || release.ReleaseGroup != "GRP" {
+		t.Errorf("Unexpected special release: %v", release)
+	}
+
+	// A year after the show's name is its year, not part of its title.
+	if release, err := importer.ParseRelease("Doctor.Who.2005.Special.Christmas.Invasion.mkv", TV); err != nil {
+		t.Fatal(err)
+	} else if release.Title != "Doctor Who" || release.Year != 2005 || release.SpecialTitle != "Christmas Invasion" {
+		t.Errorf("Unexpected special release: %v", release)
+	}
+
+	// Editions and titles which merely contain "Special" aren't specials.
+	for _, file := range []string{"Aliens.1986.Special.Edition.mkv", "The.Special.2020.mkv"} {
+		for _, mediaType := range []MediaType{TV, Documentary} {
+			if release, err := importer.ParseRelease(file, mediaType); err == nil && release.SpecialTitle != "" {
+				t.Errorf("Unexpected %v special for %v: %v", mediaType, file, release)
+			}
+		}
+
+		if release, err := importer.ParseRelease(file, Movie); err != nil || release.Year == 0 || release.Title == "" {
+			t.Errorf("Unexpected movie release for %v: %v %v", file, release, err)
+		}
+	}
+
+	provider.Seasons[1][0] = []*tvdb.Episode{
+		&tvdb.Episode{EpisodeNumber: 1, EpisodeName: "Behind the Scenes"},
+		&tvdb.Episode{EpisodeNumber: 2, EpisodeName: "Christmas Special"},
+		&tvdb.Episode{EpisodeNumber: 5, EpisodeName: "Reunion"},
+	}
+
+	testPaths := map[string]string{
+		"Some.Show.Special.Christmas.720p.HDTV-GRP.mkv": "Some Show S00E02 - Christmas Special.mkv",
+		"Some.Show.S00E05.mkv": "Some Show S00E05 - Reunion.mkv",
+	}
+
+	for file, outFile := range testPaths {
+		path := filepath.Join(dir, file)
+		writeTestFile(t, path)
+
+		if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+			t.Fatalf("Unexpected import results: %#v", results)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "TV", "Some Show", "Specials", outFile)); err != nil {
+			t.Errorf("Expected imported file at %v: %v", outFile, err)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	importer, dir, _ := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	movie, err := importer.ParsePath(filepath.Join("Movies", "Some Movie (1999)", "movie.mkv"), Movie)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if movie.Title != "Some Movie" || movie.Year != 1999 {
+		t.Errorf("Unexpected movie release: %v", movie)
+	}
+
+	// A directory naming something other than the movie is ignored.
+	movie, err = importer.ParsePath(filepath.Join("Movies 2020", "Some.Film.mkv"), Movie)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if movie.Title != "Some Film" || movie.Year != 0 {
+		t.Errorf("Unexpected movie release: %v", movie)
+	}
+
+	// An obfuscated file takes its name from a directory made for it, but not from one shared with other videos.
+	obfuscatedPath := filepath.Join(dir, "Some Movie (1999)", "abc-xyz.mkv")
+	collectionPath := filepath.Join(dir, "Movies 2020", "abc-xyz.mkv")
+	writeTestFile(t, obfuscatedPath)
+	writeTestFile(t, collectionPath)
+	writeTestFile(t, filepath.Join(dir, "Movies 2020", "Other.Film.mkv"))
+
+	if movie, err = importer.ParsePath(obfuscatedPath, Movie); err != nil || movie.Title != "Some Movie" || movie.Year != 1999 {
+		t.Errorf("Unexpected movie release: %v %v", movie, err)
+	}
+
+	if movie, err = importer.ParsePath(collectionPath, Movie); err != nil || movie.Title == "Movies" || movie.Year != 0 {
+		t.Errorf("Unexpected movie release: %v %v", movie, err)
+	}
+
+	// Episodes directly inside a show's directory.
+	for file, expected := range map[string]string{
+		filepath.Join("Shows", "Some Show", "01x03.mkv"): "Some Show 0 1 3",
+		filepath.Join("Shows", "Some Show (2010)", "S02E04.mkv"): "Some Show 2010 2 4",
+		filepath.Join("Shows", "Some Show (2010)", "Some.Show.S01E01.mkv"): "Some Show 2010 1 1",
+	} {
+		show, err := importer.ParsePath(file, TV)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := fmt.Sprintf("%v %v %v %v", show.Title, show.Year, show.Season, show.Episode()); actual != expected {
+			t.Errorf("Unexpected TV release for %v: %v", file, actual)
+		}
+	}
+
+	show, err := importer.ParsePath(filepath.Join("Shows", "Some Show", "Season 2", "E05 - Title.mkv"), TV)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if show.Title != "Some Show" || show.Season != 2 || show.Episode() != 5 {
+		t.Errorf("Unexpected TV release: %v", show)
+	}
+
+	path := filepath.Join(dir, "Some Show", "Season 1", "02 - Second.mkv")
+	writeTestFile(t, path)
+
+	if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	outPath := filepath.Join(dir, "TV", "Some Show", "Season 01", "Some Show S01E02 - Second.mkv")
+
+	if _, err := os.Stat(outPath); err != nil {
+		t.Errorf("Expected imported file at %v: %v", outPath, err)
+	}
+}
+
+func TestMultiPartMovie(t *testing.T) {
+	importer, dir, provider := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	importer.provider = &yearSearchProvider{provider}
+	provider.Titles = append(provider.Titles, imdb.Title{ID: "tt0000002", Name: "Some Movie", Year: 1999})
+
+	// A stand-in mkvmerge which records its arguments and creates the output.
+	mkvmerge := filepath.Join(dir, "mkvmerge")
+	script := "#!/bin/sh\necho \"$@\" > \"$0.args\"\ntouch \"$2\"\n"
+
+	if err := ioutil.WriteFile(mkvmerge, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	importer.config.MatroskaMuxers.MKVMerge = mkvmerge
+	source := filepath.Join(dir, "incoming")
+	part1 := filepath.Join(source, "Some.Movie.1999.CD1.avi")
+	part2 := filepath.Join(source, "Some.Movie.1999.CD2.avi")
+	writeTestFile(t, part2)
+	writeTestFile(t, part1)
+	writeTestFile(t, filepath.Join(source, "Other.Movie.2001.CD1.avi"))
+
+	sets, followers := importer.groupParts(importer.expandPaths([]string{source}))
+
+	if !reflect.DeepEqual(sets, map[string][]string{part1: []string{part1, part2}}) || followers[part2] != part1 {
+		t.Errorf("Unexpected part sets: %v %v", sets, followers)
+	}
+
+	results := importer.Import(part2, part1)
+
+	if len(results) != 2 || results[0].Err != nil || results[1].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	outPath := filepath.Join(dir, "Movies", "Some Movie (1999).mkv")
+	args, err := ioutil.ReadFile(mkvmerge + ".args")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := fmt.Sprintf("-o %v %v + %v\n", outPath, part1, part2); string(args) != expected {
+		t.Errorf("mkvmerge arguments mismatch:\n%#v\n%#v", expected, string(args))
+	}
+
+	if _, err := os.Stat(outPath); err != nil {
+		t.Errorf("Expected joined file at %v: %v", outPath, err)
+	}
+
+	// Undo needs every part, not just the first.
+	if err := os.Remove(part2); err != nil {
+		t.Fatal(err)
+	}
+
+	if results, err := importer.Undo(1, ""); err != nil || len(results) != 1 || results[0].Err == nil || !pathExists(outPath) {
+		t.Errorf("Undo deleted a joined file with a part missing: %#v %v", results, err)
+	}
+
+	// Parts matched as anything but a movie, e.g. the episodes of a documentary series, aren't joined.
+	importer.config.Patterns.Documentary = []string{`^(?P<name>.+?)\.(?P<year>\d{4})\.Part\.(?P<episode>\d)\.(?P<ext>\w+)$`}
+	importer.config.Aliases = map[string]string{"Some Doc": "documentary_local:Some Doc"}
+
+	if err := importer.compilePatterns(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := importer.compileAliases(); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(mkvmerge + ".args")
+	docPart1 := filepath.Join(source, "Some.Doc.2006.Part.1.avi")
+	docPart2 := filepath.Join(source, "Some.Doc.2006.Part.2.avi")
+	writeTestFile(t, docPart1)
+	writeTestFile(t, docPart2)
+
+	// Each part keeps its place in the output, with one report apiece.
+	var reportBuffer bytes.Buffer
+	importer.jsonOutput = true
+	importer.out = ioutil.Discard
+	importer.reportOut = &reportBuffer
+
+	showPath := filepath.Join(source, "Some.Show.S01E01.mkv")
+	writeTestFile(t, showPath)
+
+	if results := importer.Import(docPart1, docPart2, showPath); len(results) != 3 || results[0].Err != nil || results[1].Err != nil || results[2].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	decoder := json.NewDecoder(&reportBuffer)
+	reports := []FileReport{}
+
+	for decoder.More() {
+		report := FileReport{}
+
+		if err := decoder.Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+
+		reports = append(reports, report)
+	}
+
+	if len(reports) != 3 || reports[0].Path != docPart1 || reports[1].Path != docPart2 || reports[1].Outcome != "imported" || reports[2].Path != showPath {
+		t.Errorf("Unexpected reports: %#v", reports)
+	}
+
+	for _, episode := range []string{"Some Doc S01E01.mkv", "Some Doc S01E02.mkv"} {
+		if _, err := os.Stat(filepath.Join(dir, "Documentaries", "Some Doc", "Season 01", episode)); err != nil {
+			t.Errorf("Expected imported episode %v: %v", episode, err)
+		}
+	}
+}
+
+func TestRuntimes(t *testing.T) {
+	importer, dir, provider := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	// An original and its remake share a name, only the file's length tells them apart.
+	provider.Titles = append(provider.Titles,
+		imdb.Title{ID: "tt0000003", Name: "Some Film", Year: 1990, Duration: "1h30m"},
+		imdb.Title{ID: "tt0000004", Name: "Some Film", Year: 2010, Duration: "2h20m"},
+	)
+
+	// Without ffprobe runtimes aren't compared, and the output says so.
+	importer.config.MatroskaMuxers.FFMPEG = "ffmpeg"
+	path := filepath.Join(dir, "Some.Film.mkv")
+	writeTestFile(t, path)
+
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+
+	os.Setenv("PATH", dir)
+
+	if lookup := importer.lookupFile(path, nil); !bytes.Contains(lookup.output.Bytes(), []byte("Not comparing runtimes")) {
+		t.Errorf("No reason given for skipping runtimes:\n%v", lookup.output.String())
+	}
+
+	// A stand-in ffmpeg on PATH, with ffprobe next to it which reports 2 hours 20 minutes.
+	if err := ioutil.WriteFile(filepath.Join(dir, "ffmpeg"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "ffprobe"), []byte("#!/bin/sh\necho 8400.0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "Movies", "Some Film (2010).mkv")); err != nil {
+		t.Error(err)
+	}
+
+	adjustments := []struct {
+		duration, runtime time.Duration
+		episodic bool
+		expected float64
+	}{
+		{42 * time.Minute, 60 * time.Minute, true, runtimeBonus},
+		{42 * time.Minute, 2 * time.Hour, false, -runtimeBonus},
+		{2 * time.Hour, 45 * time.Minute, true, -runtimeBonus},
+		{90 * time.Minute, 140 * time.Minute, false, 0},
+	}
+
+	for _, adjustment := range adjustments {
+		if actual := runtimeAdjustment(adjustment.duration, adjustment.runtime, adjustment.episodic); actual != adjustment.expected {
+			t.Errorf("Runtime adjustment for %v against %v is %v, expected %v.", adjustment.duration, adjustment.runtime, actual, adjustment.expected)
+		}
+	}
+
+	if runtime, ok := parseRuntime("45"); !ok || runtime != 45 * time.Minute {
+		t.Errorf("Unexpected runtime: %v", runtime)
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	importer, dir, _ := setupImport(t, Options{AutomaticMode: true, DryRun: true})
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Some.Show.S01E02.720p.mkv")
+	writeTestFile(t, path)
+	results := importer.Import(path)
+
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Source file was touched in dry-run mode: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "TV")); !os.IsNotExist(err) {
+		t.Errorf("TV directory was created in dry-run mode.")
+	}
+
+	// A destination which is already taken is a planned skip, not a failure.
+	var outBuffer bytes.Buffer
+	importer.out = &outBuffer
+	writeTestFile(t, filepath.Join(dir, "TV", "Some Show", "Season 01", "Some Show S01E02 - Second.mkv"))
+
+	if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+		t.Errorf("Unexpected import results: %#v", results)
+	}
+
+	if !bytes.Contains(outBuffer.Bytes(), []byte("Plan: skip")) {
+		t.Errorf("Missing planned skip:\n%v", outBuffer.String())
+	}
+
+	// A bare mkvmerge command is found on PATH, as it would be by the real import.
+	if err := ioutil.WriteFile(filepath.Join(dir, "mkvmerge"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+
+	os.Setenv("PATH", dir)
+	importer.config.MatroskaMuxers.MKVMerge = "mkvmerge"
+
+	if method := importer.plannedImportMethod("Some.Show.S01E02.avi"); method != MKVMergeMethod {
+		t.Errorf("Unexpected planned import method: %v", method)
+	}
+}
+
+func TestMetadataCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nasimport")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	cache := NewMetadataCache(dir, time.Hour, -time.Hour)
+
+	if err = cache.Set("tvdb", "show|", []string{"Show"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cache.Set("tvdb", "missing|", []string{}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh cache must see the entries written by the first one.
+	cache = NewMetadataCache(dir, time.Hour, -time.Hour)
+	value := []string{}
+
+	if !cache.Get("tvdb", "show|", &value) || !reflect.DeepEqual(value, []string{"Show"}) {
+		t.Errorf("Cached value not found: %#v", value)
+	}
+
+	if cache.Get("tvdb", "missing|", &value) {
+		t.Errorf("Expired negative entry was returned.")
+	}
+
+	if err = cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cache.Get("tvdb", "show|", &value) {
+		t.Errorf("Cleared entry was returned.")
+	}
+}
+
+func TestCacheUnwritable(t *testing.T) {
+	importer, dir, _ := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	// The cache only saves time, a file still matches when it can't be written.
+	blocked := filepath.Join(dir, "blocked")
+	writeTestFile(t, blocked)
+	importer.cache = NewMetadataCache(filepath.Join(blocked, "cache"), time.Hour, time.Hour)
+	var outBuffer bytes.Buffer
+	importer.out = &outBuffer
+	path := filepath.Join(dir, "Some.Show.S01E02.mkv")
+	writeTestFile(t, path)
+
+	if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	if !bytes.Contains(outBuffer.Bytes(), []byte("Warning: couldn't cache")) {
+		t.Errorf("Missing cache warning:\n%v", outBuffer.String())
+	}
+}
+
+func TestFindVideoFiles(t *testing.T) {
+	importer := setup(t)
+	dir, err := ioutil.TempDir("", "nasimport")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	files := []string{
+		"Show.S01/Show.S01E01.mkv",
+		"Show.S01/Show.S01E02.avi",
+		"Show.S01/Show.S01E02.nfo",
+		"Show.S01/Show.S01E01.sample.mkv",
+		"Show.S01/Sample/Show.S01E01.mkv",
+		"Show.S01/.hidden/Show.S01E03.mkv",
+		"Show.S01/.Show.S01E04.mkv",
+	}
+
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := importer.findVideoFiles(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "Show.S01/Show.S01E01.mkv"),
+		filepath.Join(dir, "Show.S01/Show.S01E02.avi"),
+	}
+
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Video file mismatch:\n%#v\n%#v", expected, paths)
+	}
+
+	// Parts of the tree which can't be read stop the walk, unless they are skipped.
+	missing := filepath.Join(dir, "missing")
+
+	if _, err := importer.findVideoFiles(missing); err == nil {
+		t.Errorf("Unreadable directory was walked.")
+	}
+
+	skipped := []string{}
+
+	if _, err := importer.walkVideoFiles(missing, func(path string, err error) error {
+		skipped = append(skipped, path)
+
+		return nil
+	}); err != nil || !reflect.DeepEqual(skipped, []string{missing}) {
+		t.Errorf("Unreadable directory wasn't skipped: %v %v", skipped, err)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	importer, dir, _ := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Some.Show.S01E01.mkv")
+	outPath := filepath.Join(dir, "TV", "Some Show", "Season 01", "Some Show S01E01 - Pilot.mkv")
+	writeTestFile(t, path)
+
+	if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	results, err := importer.Undo(1, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected undo results: %#v", results)
+	}
+
+	if _, err = os.Stat(path); err != nil {
+		t.Errorf("Source file wasn't restored: %v", err)
+	}
+
+	if _, err = os.Stat(outPath); !os.IsNotExist(err) {
+		t.Errorf("Imported file still exists after undo.")
+	}
+
+	// Entries that have been undone must not be undone again.
+	if results, err = importer.Undo(1, ""); err != nil || len(results) != 0 {
+		t.Errorf("Unexpected second undo results: %#v %v", results, err)
+	}
+
+	// A whole batch is undone together, and a batch with nothing to undo is an error.
+	if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	if results, err = importer.Undo(1, importer.Batch()); err != nil || len(results) != 1 || results[0].Err != nil {
+		t.Errorf("Unexpected batch undo results: %#v %v", results, err)
+	}
+
+	if results, err = importer.Undo(1, "unknown"); err == nil {
+		t.Errorf("Unknown batch undone: %#v", results)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	importer, dir, _ := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	importer.out = ioutil.Discard
+	importer.config.Watch.SettleSeconds = 1
+	incoming := filepath.Join(dir, "incoming")
+
+	if err := os.MkdirAll(incoming, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- importer.watch(incoming, stop)
+	}()
+
+	// The file keeps growing for a while after it appears.
+	path := filepath.Join(incoming, "Some.Show.S01E02.mkv")
+	writeTestFile(t, path)
+	time.Sleep(200 * time.Millisecond)
+
+	if err := ioutil.WriteFile(path, []byte("more video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outPath := filepath.Join(dir, "TV", "Some Show", "Season 01", "Some Show S01E02 - Second.mkv")
+
+	for deadline := time.Now().Add(10 * time.Second); !pathExists(outPath); time.Sleep(100 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("File wasn't imported: %v", outPath)
+		}
+	}
+
+	// Give the watcher time to import it again if it were going to.
+	time.Sleep(2 * time.Second)
+	close(stop)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if outBytes, err := ioutil.ReadFile(outPath); err != nil || string(outBytes) != "more video" {
+		t.Errorf("Imported before the file settled: %q, %v", outBytes, err)
+	}
+
+	entries, err := importer.journal.Read()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Source != path {
+		t.Errorf("Unexpected journal entries: %#v", entries)
+	}
+}
+
+// flakyProvider fails the first TheTVDB searches, like a brief outage.
+type flakyProvider struct {
+	*yearSearchProvider
+	failures int
+}
+
+func (provider *flakyProvider) SearchSeries(name string, maxResults int) (seriesList tvdb.SeriesList, err error) {
+	if provider.failures > 0 {
+		provider.failures--
+
+		return seriesList, errors.New("TheTVDB is down")
+	}
+
+	return provider.MemoryProvider.SearchSeries(name, maxResults)
+}
+
+func TestImportSettled(t *testing.T) {
+	importer, dir, provider := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	importer.out = ioutil.Discard
+	importer.provider = &flakyProvider{&yearSearchProvider{provider}, 1}
+	now := time.Now()
+	path := filepath.Join(dir, "incoming", "Some.Show.S01E02.mkv")
+	writeTestFile(t, path)
+	pending := map[string]watchedFile{path: watchedFile{size: 5, changed: now.Add(-time.Minute)}}
+
+	// A file which fails is kept, and only tried again once it has waited.
+	importer.importSettled(pending, now, time.Second)
+
+	if file, ok := pending[path]; !ok || file.attempts != 1 || !file.retry.Equal(now.Add(time.Second)) {
+		t.Fatalf("Failed file wasn't kept for another try: %#v", pending)
+	}
+
+	importer.importSettled(pending, now.Add(500 * time.Millisecond), time.Second)
+
+	if !pathExists(path) {
+		t.Fatalf("File was tried again too soon.")
+	}
+
+	importer.importSettled(pending, now.Add(time.Second), time.Second)
+
+	if _, ok := pending[path]; ok || pathExists(path) {
+		t.Errorf("File wasn't imported on the second try: %#v", pending)
+	}
+
+	// The parts of a movie wait for each other and are joined.
+	provider.Titles = append(provider.Titles, imdb.Title{ID: "tt0000002", Name: "Some Movie", Year: 1999})
+	mkvmerge := filepath.Join(dir, "mkvmerge")
+
+	if err := ioutil.WriteFile(mkvmerge, []byte("#!/bin/sh\ntouch \"$2\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	importer.config.MatroskaMuxers.MKVMerge = mkvmerge
+	part1 := filepath.Join(dir, "incoming", "Some.Movie.1999.CD1.avi")
+	part2 := filepath.Join(dir, "incoming", "Some.Movie.1999.CD2.avi")
+	writeTestFile(t, part1)
+	writeTestFile(t, part2)
+	pending[part1] = watchedFile{size: 5, changed: now.Add(-time.Minute)}
+	pending[part2] = watchedFile{size: 5, changed: now}
+	importer.importSettled(pending, now, time.Second)
+
+	if len(pending) != 2 {
+		t.Fatalf("A part was imported before the others settled: %#v", pending)
+	}
+
+	importer.importSettled(pending, now.Add(time.Second), time.Second)
+	outPath := filepath.Join(dir, "Movies", "Some Movie (1999).mkv")
+
+	if len(pending) != 0 || !pathExists(outPath) {
+		t.Errorf("Parts weren't joined: %#v", pending)
+	}
+}
+
+func TestQueueUncertain(t *testing.T) {
+	importer, dir, provider := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	// Two different series with the same name can't be told apart automatically.
+	provider.Series = append(provider.Series, tvdb.Series{Id: 2, SeriesName: "Some Show"})
+	path := filepath.Join(dir, "Some.Show.S01E01.mkv")
+	writeTestFile(t, path)
+
+	if results := importer.Import(path); len(results) != 1 || results[0].Err != ErrQueuedForReview {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	// Neither can a match below the minimum score.
+	provider.Series = provider.Series[: 1]
+	importer.config.Automatic.MinScore = 0.95
+	lowScorePath := filepath.Join(dir, "Some.Sho.S01E01.mkv")
+	writeTestFile(t, lowScorePath)
+
+	if results := importer.Import(lowScorePath); len(results) != 1 || results[0].Err != ErrQueuedForReview {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	items, err := importer.reviewQueue.Read()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 2 || items[0].Path != path || items[1].Path != lowScorePath {
+		t.Errorf("Unexpected review queue: %#v", items)
+	}
+
+	// A single clear match is still imported once the duplicate series is gone.
+	importer.config.Automatic.MinScore = 0
+	path = filepath.Join(dir, "Some.Show.S01E02.mkv")
+
+	if err := importer.ClearCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFile(t, path)
+
+	if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+}
+
+func TestPreviousChoices(t *testing.T) {
+	importer, dir, provider := setupImport(t, Options{})
+
+	defer os.RemoveAll(dir)
+
+	provider.Series = append(provider.Series, tvdb.Series{Id: 2, SeriesName: "Some Show (2010)"})
+	provider.Seasons[2] = provider.Seasons[1]
+
+	// Answer the prompts: the second best match for the first file, then the best for the second.
+	stdinReader, stdinWriter, err := os.Pipe()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdin := os.Stdin
+	os.Stdin = stdinReader
+
+	defer func() {
+		os.Stdin = stdin
+	}()
+
+	if _, err := stdinWriter.WriteString("2\n1\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	stdinWriter.Close()
+
+	// Both files are looked up before the first prompt, the choice must still carry over to the second.
+	paths := []string{filepath.Join(dir, "Some.Show.S01E01.mkv"), filepath.Join(dir, "Some.Show.S01E02.mkv")}
+
+	for _, path := range paths {
+		writeTestFile(t, path)
+	}
+
+	for _, result := range importer.Import(paths...) {
+		if result.Err != nil {
+			t.Fatalf("Unexpected import result: %#v", result)
+		}
+	}
+
+	// Automatic mode takes the previous choice over the better scoring series.
+	importer.automaticMode = true
+	provider.Seasons[1][1] = append(provider.Seasons[1][1], &tvdb.Episode{EpisodeNumber: 3, EpisodeName: "Third"})
+	path := filepath.Join(dir, "Some.Show.S01E03.mkv")
+	writeTestFile(t, path)
+
+	if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	entries, err := importer.journal.Read()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 3 || entries[0].ProviderId != "tvdb:2" || entries[1].ProviderId != "tvdb:2" || entries[2].ProviderId != "tvdb:2" {
+		t.Errorf("Unexpected journal entries: %#v", entries)
+	}
+
+	// Choices are kept on disk for later runs.
+	if _, err := os.Stat(filepath.Join(dir, "choices.json")); err != nil {
+		t.Error(err)
+	}
+
+	// A choices file which can't be read is left alone rather than overwritten.
+	store := NewChoiceStore(filepath.Join(dir, "broken.json"))
+	ioutil.WriteFile(store.path, []byte("{"), 0644)
+
+	if err := store.Set("tv|some show", Choice{}); err == nil {
+		t.Errorf("Choice saved over an unreadable file.")
+	}
+
+	if choicesBytes, _ := ioutil.ReadFile(store.path); string(choicesBytes) != "{" {
+		t.Errorf("Unreadable choices file overwritten: %v", string(choicesBytes))
+	}
+}
+
+func TestLibrary(t *testing.T) {
+	importer, dir, provider := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	importer.provider = &yearSearchProvider{provider}
+	provider.Titles = append(provider.Titles, imdb.Title{ID: "tt0000002", Name: "Some Movie", Year: 1999})
+	episodePath := filepath.Join(dir, "TV", "Some Show", "Season 01", "Some Show S01E01-E02 - Pilot & Second.avi")
+	moviePath := filepath.Join(dir, "Movies", "Some Movie (1999)", "Some Movie (1999).avi")
+	writeTestFile(t, episodePath)
+	writeTestFile(t, moviePath)
+	writeTestFile(t, filepath.Join(dir, "Movies", "Some Movie (1999) {edition-Extended}.avi"))
+
+	if err := importer.ReadExistingMedia(); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := importer.library.SeriesNames(TV); !reflect.DeepEqual(names, []string{"Some Show"}) {
+		t.Errorf("Unexpected series: %v", names)
+	}
+
+	if path := importer.library.series[TV]["some show"].Seasons[1][2]; path != episodePath {
+		t.Errorf("Unexpected episode path: %v", path)
+	}
+
+	if len(importer.library.movies[Movie]) != 2 || importer.library.movies[Movie]["some movie (1999)|"].Path != moviePath {
+		t.Errorf("Unexpected movies: %#v", importer.library.movies[Movie])
+	}
+
+	// Episodes and movies already in the library are imported with a warning.
+	var outBuffer bytes.Buffer
+	importer.out = &outBuffer
+	incoming := filepath.Join(dir, "incoming")
+	writeTestFile(t, filepath.Join(incoming, "Some.Show.S01E02.mkv"))
+	writeTestFile(t, filepath.Join(incoming, "Some.Movie.1999.mkv"))
+
+	for _, result := range importer.Import(incoming) {
+		if result.Err != nil {
+			t.Errorf("Unexpected import result: %#v", result)
+		}
+	}
+
+	for _, existing := range []string{episodePath, moviePath} {
+		if !bytes.Contains(outBuffer.Bytes(), []byte("Warning: already in the library as " + existing)) {
+			t.Errorf("No warning about %v:\n%v", existing, outBuffer.String())
+		}
+	}
+
+	// Imported files are indexed straight away.
+	if path := importer.library.series[TV]["some show"].Seasons[1][2]; path != filepath.Join(dir, "TV", "Some Show", "Season 01", "Some Show S01E02 - Second.mkv") {
+		t.Errorf("Unexpected episode path: %v", path)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	if _, err := compileTemplate("tv_episode", `{{.Series}}/{{.Nonsense}}.mkv`, defaultTVEpisodeTemplate); err == nil {
+		t.Errorf("Template with unknown placeholder was accepted.")
+	}
+
+	if _, err := compileTemplate("movie", `../{{.Title}}.mkv`, defaultMovieTemplate); err == nil {
+		t.Errorf("Template leaving the media directory was accepted.")
+	}
+
+	pathTemplate, err := compileTemplate("tv_episode", `{{.Series}} [tvdb-{{.TVDBId}}]/{{.Series}} - {{.Season}}x{{pad .Episode}}.{{.Ext}}`, defaultTVEpisodeTemplate)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	importer := setup(t)
+	outPath, err := importer.buildPath(pathTemplate, "/TV", PathFields{Series: "AC/DC", Season: 1, Episode: 2, TVDBId: 5})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "/TV/AC∕DC [tvdb-5]/AC∕DC - 1x02.mkv"; outPath != expected {
+		t.Errorf("Path mismatch:\n%#v\n%#v", expected, outPath)
+	}
+
+	// Names from providers are checked once rendered too.
+	if outPath, err := importer.buildPath(importer.movieTemplate, "/Movies", PathFields{Title: "..", Year: 2000}); err != nil {
+		t.Fatal(err)
+	} else if outPath != "/Movies/.. (2000).mkv" {
+		t.Errorf("Unexpected path: %v", outPath)
+	}
+
+	if _, err := importer.buildPath(importer.tvEpisodeTemplate, "/TV", PathFields{Series: "..", Season: 1, Episode: 2}); err == nil {
+		t.Errorf("Path leaving the media directory was accepted.")
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	if _, err := compilePattern("tv", `(?P<name>.+)\.(?P<ext>\w+)$`, []string{"name", "season", "episode", "ext"}); err == nil {
+		t.Errorf("Pattern without season and episode groups was accepted.")
+	}
+
+	if _, err := compilePattern("movies", `(?P<name>.+`, []string{"name", "ext"}); err == nil {
+		t.Errorf("Invalid pattern was accepted.")
+	}
+
+	importer := setup(t)
+	importer.config.Patterns.TV = []string{`^(?P<name>.+?)\.Ep(?P<episode>\d+)\.Se(?P<season>\d+)\.(?P<ext>\w+)$`}
+
+	if err := importer.compilePatterns(); err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := importer.ParseRelease("Some.Show.Ep02.Se01.mkv", TV)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if release.Title != "Some Show" || release.Season != 1 || release.Episode() != 2 {
+		t.Errorf("Unexpected release from user pattern: %v", release)
+	}
+}
+
+// yearSearchProvider understands a trailing year in IMDb searches, e.g. "Name (1999)", as IMDb does for movies
+// searched by name and year.
+type yearSearchProvider struct {
+	*MemoryProvider
+}
+
+func (provider *yearSearchProvider) SearchTitles(name string) (titles []imdb.Title, err error) {
+	for _, title := range provider.Titles {
+		if provider.matches(fmt.Sprintf("%v (%v)", title.Name, title.Year), name) {
+			titles = append(titles, title)
+		}
+	}
+
+	return
+}
+
+func TestMovieEditions(t *testing.T) {
+	importer, dir, provider := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	importer.provider = &yearSearchProvider{provider}
+	provider.Titles = append(provider.Titles, imdb.Title{ID: "tt0083658", Name: "Blade Runner", Year: 1982})
+
+	testPaths := map[string]string{
+		"Blade.Runner.1982.1080p.BluRay.x264.mkv": "Blade Runner (1982).mkv",
+		"Blade.Runner.1982.Final.Cut.1080p.BluRay.x264.mkv": "Blade Runner (1982) {edition-Final Cut}.mkv",
+		"Blade.Runner.1982.Directors.Cut.720p.mkv": "Blade Runner (1982) {edition-Director's Cut}.mkv",
+	}
+
+	for file, outFile := range testPaths {
+		path := filepath.Join(dir, file)
+		writeTestFile(t, path)
+
+		if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+			t.Fatalf("Unexpected import results: %#v", results)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "Movies", outFile)); err != nil {
+			t.Errorf("Expected imported file at %v: %v", outFile, err)
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	for _, target := range []string{"tvdb", "tvdb:abc", "nowhere:1"} {
+		if _, err := parseAlias(target); err == nil {
+			t.Errorf("Invalid alias %q was accepted.", target)
+		}
+	}
+
+	// Aliases skip the prompt, so this doesn't need automatic mode.
+	importer, dir, provider := setupImport(t, Options{})
+
+	defer os.RemoveAll(dir)
+
+	provider.Series = append(provider.Series,
+		tvdb.Series{Id: 5, SeriesName: "Shameless (US)"},
+		tvdb.Series{Id: 6, SeriesName: "Doctor Who (2005)"},
+		tvdb.Series{Id: 7, SeriesName: "Doctor Who"},
+	)
+	provider.Seasons[5] = map[uint64][]*tvdb.Episode{1: []*tvdb.Episode{&tvdb.Episode{EpisodeNumber: 1, EpisodeName: "Pilot"}}}
+	provider.Seasons[6] = map[uint64][]*tvdb.Episode{1: []*tvdb.Episode{&tvdb.Episode{EpisodeNumber: 1, EpisodeName: "Rose"}}}
+	importer.config.Aliases = map[string]string{
+		"Shameless US": "tvdb:5",
+		"Doctor Who (2005)": "tvdb:6",
+	}
+
+	if err := importer.compileAliases(); err != nil {
+		t.Fatal(err)
+	}
+
+	testPaths := map[string]string{
+		"Shameless.US.S01E01.mkv": filepath.Join("Shameless (US)", "Season 01", "Shameless (US) S01E01 - Pilot.mkv"),
+		"Doctor.Who.2005.S01E01.mkv": filepath.Join("Doctor Who (2005)", "Season 01", "Doctor Who (2005) S01E01 - Rose.mkv"),
+	}
+
+	for file, outFile := range testPaths {
+		path := filepath.Join(dir, file)
+		writeTestFile(t, path)
+
+		if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+			t.Fatalf("Unexpected import results: %#v", results)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "TV", outFile)); err != nil {
+			t.Errorf("Expected imported file at %v: %v", outFile, err)
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	importer, dir, _ := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	unknownPath := filepath.Join(dir, "Some.Show.S03E01.mkv")
+	firstPath := filepath.Join(dir, "Some.Show.S01E01.mkv")
+	duplicatePath := filepath.Join(dir, "duplicate", "Some.Show.S01E01.mkv")
+	writeTestFile(t, unknownPath)
+	writeTestFile(t, firstPath)
+	writeTestFile(t, duplicatePath)
+
+	results := importer.Import(unknownPath, firstPath, duplicatePath)
+
+	if len(results) != 3 {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	if !errors.Is(results[0].Err, ErrNoMatch) {
+		t.Errorf("Expected ErrNoMatch, got %v", results[0].Err)
+	}
+
+	if results[1].Err != nil {
+		t.Errorf("Unexpected error: %v", results[1].Err)
+	}
+
+	if !errors.Is(results[2].Err, ErrDestinationExists) {
+		t.Errorf("Expected ErrDestinationExists, got %v", results[2].Err)
+	}
+
+	// A destination which can't be checked isn't taken for an existing one.
+	tvDir := importer.config.MediaDirs.TVDir
+	blocked := filepath.Join(dir, "blocked")
+	writeTestFile(t, blocked)
+	importer.config.MediaDirs.TVDir = blocked
+	secondPath := filepath.Join(dir, "Some.Show.S01E02.mkv")
+	writeTestFile(t, secondPath)
+
+	if results := importer.Import(secondPath); len(results) != 1 || results[0].Err == nil || errors.Is(results[0].Err, ErrDestinationExists) {
+		t.Errorf("Unexpected import results: %#v", results)
+	}
+
+	// A file which was moved is imported even if the journal can't be written.
+	var outBuffer bytes.Buffer
+	importer.out = &outBuffer
+	importer.config.MediaDirs.TVDir = tvDir
+	importer.journal = NewJournal(filepath.Join(blocked, "journal.jsonl"))
+
+	if results := importer.Import(secondPath); len(results) != 1 || results[0].Err != nil {
+		t.Errorf("Unexpected import results: %#v", results)
+	}
+
+	if !bytes.Contains(outBuffer.Bytes(), []byte("Warning: couldn't record import in the journal")) {
+		t.Errorf("Missing journal warning:\n%v", outBuffer.String())
+	}
+}
+
+// unavailableProvider fails every IMDb search, like IMDb during an outage.
+type unavailableProvider struct {
+	*MemoryProvider
+}
+
+func (provider *unavailableProvider) SearchTitles(name string) (titles []imdb.Title, err error) {
+	return nil, errors.New("IMDb is down")
+}
+
+func TestProviderUnavailable(t *testing.T) {
+	importer, dir, provider := setupImport(t, Options{AutomaticMode: true})
+
+	defer os.RemoveAll(dir)
+
+	importer.provider = &unavailableProvider{provider}
+	path := filepath.Join(dir, "Some.Movie.1999.mkv")
+	writeTestFile(t, path)
+
+	if results := importer.Import(path); len(results) != 1 || !errors.Is(results[0].Err, ErrProviderUnavailable) {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	// The failed search mustn't be cached as finding nothing.
+	stats, err := importer.CacheStats()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bucketStats := range stats {
+		if bucketStats.Bucket == "imdb" {
+			t.Errorf("Failed search was cached: %#v", bucketStats)
+		}
+	}
+
+	// TheTVDB still matches a TV show while IMDb is down.
+	path = filepath.Join(dir, "Some.Show.S01E02.mkv")
+	writeTestFile(t, path)
+
+	if results := importer.Import(path); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	// A title whose genres can't be fetched is skipped, the others are still found.
+	provider.Titles = append(provider.Titles,
+		imdb.Title{ID: "tt0000010", Name: "Nature Film", Year: 2001, Genres: []string{"Documentary"}},
+		imdb.Title{ID: "tt0000011", Name: "Nature Film Returns", Year: 2003, Genres: []string{"Documentary"}},
+	)
+	importer.provider = &missingTitleProvider{provider, "tt0000011"}
+	var output bytes.Buffer
+	titles, err := importer.detectIMDBMovie(&output, "Nature Film", "documentary")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(titles) != 1 || titles[0].ID != "tt0000010" || !bytes.Contains(output.Bytes(), []byte("Warning: skipping tt0000011")) {
+		t.Errorf("Unexpected titles: %#v\n%v", titles, output.String())
+	}
+}
+
+// missingTitleProvider fails to fetch one title, like IMDb timing out on a single request.
+type missingTitleProvider struct {
+	*MemoryProvider
+	id string
+}
+
+func (provider *missingTitleProvider) GetTitle(id string) (title imdb.Title, err error) {
+	if id == provider.id {
+		return title, errors.New("IMDb timed out")
+	}
+
+	return provider.MemoryProvider.GetTitle(id)
+}
+
+func TestJSONOutput(t *testing.T) {
+	importer, dir, _ := setupImport(t, Options{AutomaticMode: true, DryRun: true, JSONOutput: true})
+
+	defer os.RemoveAll(dir)
+
+	var reportBuffer bytes.Buffer
+	importer.out = ioutil.Discard
+	importer.reportOut = &reportBuffer
+	path := filepath.Join(dir, "Some.Show.S01E02.mkv")
+	writeTestFile(t, path)
+	importer.Import(path, filepath.Join(dir, "missing.mkv"))
+
+	decoder := json.NewDecoder(&reportBuffer)
+	reports := []FileReport{}
+
+	for decoder.More() {
+		report := FileReport{}
+
+		if err := decoder.Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+
+		reports = append(reports, report)
+	}
+
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %#v", reports)
+	}
+
+	if reports[0].Outcome != "planned" || reports[0].Match == nil || reports[0].Match.ProviderId != "tvdb:1" || reports[0].TVShowFields.Season != 1 {
+		t.Errorf("Unexpected report: %#v", reports[0])
+	}
+
+	if reports[1].Outcome != "failed" || reports[1].Error == "" {
+		t.Errorf("Unexpected report: %#v", reports[1])
+	}
+
+	// Wrapped errors keep their outcome.
+	reportBuffer.Reset()
+	importer.writeReport(FileReport{Path: path}, fmt.Errorf("%w: low score", ErrQueuedForReview))
+	report := FileReport{}
+
+	if err := json.Unmarshal(reportBuffer.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Outcome != "queued" {
+		t.Errorf("Unexpected report: %#v", report)
+	}
+}
+
+// slowProvider delays searches, the earliest longest, so that lookups finish out of order.
+type slowProvider struct {
+	*MemoryProvider
+	mutex sync.Mutex
+	calls int
+	running int
+	maxRunning int
+}
+
+func (provider *slowProvider) wait() {
+	provider.mutex.Lock()
+	provider.calls++
+	provider.running++
+	delay := time.Duration(10 - provider.calls % 10) * 5 * time.Millisecond
+
+	if provider.running > provider.maxRunning {
+		provider.maxRunning = provider.running
+	}
+
+	provider.mutex.Unlock()
+	time.Sleep(delay)
+	provider.mutex.Lock()
+	provider.running--
+	provider.mutex.Unlock()
+}
+
+func (provider *slowProvider) SearchSeries(name string, maxResults int) (seriesList tvdb.SeriesList, err error) {
+	provider.wait()
+
+	return provider.MemoryProvider.SearchSeries(name, maxResults)
+}
+
+func (provider *slowProvider) SearchTitles(name string) (titles []imdb.Title, err error) {
+	provider.wait()
+
+	return provider.MemoryProvider.SearchTitles(name)
+}
+
+func TestLookupWorkers(t *testing.T) {
+	importer, dir, provider := setupImport(t, Options{JSONOutput: true})
+
+	defer os.RemoveAll(dir)
+
+	slow := &slowProvider{MemoryProvider: provider}
+	importer.provider = slow
+	importer.config.Interface.LookupWorkers = 4
+	paths := []string{}
+
+	for episode := uint64(1); episode <= 6; episode++ {
+		if episode > 2 {
+			provider.Seasons[1][1] = append(provider.Seasons[1][1], &tvdb.Episode{EpisodeNumber: episode, EpisodeName: fmt.Sprintf("Episode %v", episode)})
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("Some.Show.S01E%02d.mkv", episode))
+		writeTestFile(t, path)
+		paths = append(paths, path)
+	}
+
+	// Every file asks for the best match.
+	stdinReader, stdinWriter, err := os.Pipe()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdin := os.Stdin
+	os.Stdin = stdinReader
+
+	defer func() {
+		os.Stdin = stdin
+	}()
+
+	if _, err := stdinWriter.WriteString(strings.Repeat("1\n", len(paths))); err != nil {
+		t.Fatal(err)
+	}
+
+	stdinWriter.Close()
+
+	var outBuffer, reportBuffer bytes.Buffer
+	importer.out = &outBuffer
+	importer.reportOut = &reportBuffer
+	results := importer.Import(paths...)
+
+	if len(results) != len(paths) {
+		t.Fatalf("Unexpected import results: %#v", results)
+	}
+
+	for index, result := range results {
+		if result.Path != paths[index] || result.Err != nil {
+			t.Errorf("Unexpected import result %v: %#v", index, result)
+		}
+	}
+
+	if slow.maxRunning < 2 {
+		t.Errorf("Lookups didn't run concurrently.")
+	}
+
+	decoder := json.NewDecoder(&reportBuffer)
+	reports := []FileReport{}
+
+	for decoder.More() {
+		report := FileReport{}
+
+		if err := decoder.Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+
+		reports = append(reports, report)
+	}
+
+	if len(reports) != len(paths) {
+		t.Fatalf("Expected %v reports, got %#v", len(paths), reports)
+	}
+
+	for index, report := range reports {
+		if report.Path != paths[index] || report.Outcome != "imported" {
+			t.Errorf("Unexpected report %v: %#v", index, report)
+		}
+	}
+
+	// Each file's lookup output is followed by its own prompt before the next file's begins.
+	files := strings.Split(outBuffer.String(), "Importing ")[1 :]
+
+	if len(files) != len(paths) {
+		t.Fatalf("Unexpected output:\n%v", outBuffer.String())
+	}
+
+	for index, output := range files {
+		if !strings.HasPrefix(output, paths[index] + "\n") || strings.Count(output, "Enter ID: ") != 1 {
+			t.Errorf("Unexpected output for %v:\n%v", paths[index], output)
+		}
+	}
+}
@@ -0,0 +1,693 @@
+package nasimporter
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"github.com/garfunkel/go-mapregexp"
+)
+
+// ParsedRelease holds everything that could be read from a release file name.
+type ParsedRelease struct {
+	Title string `json:"title"`
+	Year uint64 `json:"year,omitempty"`
+	HasSeason bool `json:"has_season"`
+	Season uint64 `json:"season"`
+	Episodes []uint64 `json:"episodes,omitempty"`
+	AirDate string `json:"air_date,omitempty"`
+	AbsoluteEpisode uint64 `json:"absolute_episode,omitempty"`
+	SpecialTitle string `json:"special_title,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+	Source string `json:"source,omitempty"`
+	VideoCodec string `json:"video_codec,omitempty"`
+	AudioCodec string `json:"audio_codec,omitempty"`
+	ReleaseGroup string `json:"release_group,omitempty"`
+	Edition string `json:"edition,omitempty"`
+	Part uint64 `json:"part,omitempty"`
+	Languages []string `json:"languages,omitempty"`
+	Other string `json:"other,omitempty"`
+	Ext string `json:"ext"`
+}
+
+func (release *ParsedRelease) HasEpisode() bool {
+	return len(release.Episodes) > 0
+}
+
+func (release *ParsedRelease) Episode() uint64 {
+	if len(release.Episodes) == 0 {
+		return 0
+	}
+
+	return release.Episodes[0]
+}
+
+// IdentifiesEpisode reports whether the release can be resolved to a single episode of a series.
+func (release *ParsedRelease) IdentifiesEpisode() bool {
+	return release.HasEpisode() || release.AirDate != "" || release.AbsoluteEpisode != 0 || release.SpecialTitle != ""
+}
+
+// LastEpisode is the final episode of a multi-episode file, or 0 if the file holds a single episode.
+func (release *ParsedRelease) LastEpisode() uint64 {
+	if len(release.Episodes) < 2 {
+		return 0
+	}
+
+	return release.Episodes[len(release.Episodes) - 1]
+}
+
+// SearchName is the title used for provider searches, with the year appended if known.
+func (release *ParsedRelease) SearchName() string {
+	if release.Year != 0 {
+		return fmt.Sprintf("%v (%v)", release.Title, release.Year)
+	}
+
+	return release.Title
+}
+
+func (release ParsedRelease) String() string {
+	parts := []string{fmt.Sprintf("title=%q", release.Title)}
+
+	if release.Year != 0 {
+		parts = append(parts, fmt.Sprintf("year=%v", release.Year))
+	}
+
+	if release.HasSeason {
+		parts = append(parts, fmt.Sprintf("season=%v", release.Season))
+	}
+
+	if release.HasEpisode() {
+		parts = append(parts, fmt.Sprintf("episodes=%v", release.Episodes))
+	}
+
+	if release.AirDate != "" {
+		parts = append(parts, "air_date=" + release.AirDate)
+	}
+
+	if release.AbsoluteEpisode != 0 {
+		parts = append(parts, fmt.Sprintf("absolute_episode=%v", release.AbsoluteEpisode))
+	}
+
+	if release.SpecialTitle != "" {
+		parts = append(parts, fmt.Sprintf("special=%q", release.SpecialTitle))
+	}
+
+	if release.Part != 0 {
+		parts = append(parts, fmt.Sprintf("part=%v", release.Part))
+	}
+
+	for _, tag := range [...][2]string{
+		{"resolution", release.Resolution},
+		{"source", release.Source},
+		{"video_codec", release.VideoCodec},
+		{"audio_codec", release.AudioCodec},
+		{"group", release.ReleaseGroup},
+		{"edition", release.Edition},
+		{"languages", strings.Join(release.Languages, ",")},
+		{"ext", release.Ext},
+	} {
+		if tag[1] != "" {
+			parts = append(parts, tag[0] + "=" + tag[1])
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+const maxEpisodeRange = 20
+
+var canonicalTags = map[string]string{
+	"4k": "2160p",
+	"uhd": "2160p",
+	"webdl": "WEB-DL",
+	"webrip": "WEBRip",
+	"web": "WEB",
+	"bluray": "BluRay",
+	"bdrip": "BDRip",
+	"brrip": "BRRip",
+	"bdremux": "BDRemux",
+	"remux": "Remux",
+	"hdtv": "HDTV",
+	"pdtv": "PDTV",
+	"dvdrip": "DVDRip",
+	"dvd": "DVD",
+	"hdrip": "HDRip",
+	"x264": "x264",
+	"x265": "x265",
+	"h264": "H.264",
+	"h265": "H.265",
+	"hevc": "HEVC",
+	"avc": "AVC",
+	"xvid": "XviD",
+	"divx": "DivX",
+	"av1": "AV1",
+	"vp9": "VP9",
+	"dd51": "DD5.1",
+	"dd20": "DD2.0",
+	"dd+51": "DD+5.1",
+	"ddp51": "DD+5.1",
+	"dd+20": "DD+2.0",
+	"ddp20": "DD+2.0",
+	"dtshd": "DTS-HD",
+	"dtshdma": "DTS-HD MA",
+	"truehd": "TrueHD",
+	"atmos": "Atmos",
+	"flac": "FLAC",
+	"opus": "Opus",
+	"directorscut": "Director's Cut",
+	"extended": "Extended",
+	"extendededition": "Extended",
+	"extendedcut": "Extended",
+	"unrated": "Unrated",
+	"uncut": "Uncut",
+	"remastered": "Remastered",
+	"imax": "IMAX",
+	"theatrical": "Theatrical",
+	"theatricalcut": "Theatrical",
+	"finalcut": "Final Cut",
+	"specialedition": "Special Edition",
+	"collectorsedition": "Collector's Edition",
+	"anniversaryedition": "Anniversary Edition",
+	"ultimateedition": "Ultimate Edition",
+	"criterion": "Criterion",
+	"dualaudio": "DUAL",
+}
+
+func canonicalTag(tag string) string {
+	key := strings.ToLower(tag)
+
+	for _, separator := range []string{".", "-", "_", " ", "'"} {
+		key = strings.Replace(key, separator, "", -1)
+	}
+
+	if canonical, ok := canonicalTags[key]; ok {
+		return canonical
+	}
+
+	return strings.ToUpper(tag)
+}
+
+func isAlphanumeric(char byte) bool {
+	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+}
+
+// findTags returns the spans of tagRegex matches in text that stand alone, i.e. aren't part of a longer word.
+func findTags(tagRegex *regexp.Regexp, text string) (spans [][]int) {
+	for _, span := range tagRegex.FindAllStringIndex(text, -1) {
+		if span[0] > 0 && isAlphanumeric(text[span[0] - 1]) {
+			continue
+		}
+
+		if span[1] < len(text) && isAlphanumeric(text[span[1]]) {
+			continue
+		}
+
+		spans = append(spans, span)
+	}
+
+	return
+}
+
+func (importer *NasImporter) compileReleaseRegexes() {
+	importer.resolutionRegex = regexp.MustCompile(`(?i)\d{3,4}[pi]|4k|uhd`)
+	importer.releaseSourceRegex = regexp.MustCompile(`(?i)web[\.\-_ ]?dl|web[\.\-_ ]?rip|blu[\.\-_ ]?ray|bd[\.\-_ ]?rip|br[\.\-_ ]?rip|bd[\.\-_ ]?remux|remux|hdtv|pdtv|dvd[\.\-_ ]?rip|dvd|hd[\.\-_ ]?rip|web`)
+	importer.videoCodecRegex = regexp.MustCompile(`(?i)[xh][\.\-_ ]?26[45]|hevc|avc|xvid|divx|av1|vp9`)
+	importer.audioCodecRegex = regexp.MustCompile(`(?i)ddp?\+?[\.\-_ ]?[257][\.\-_ ]?[01]|e?ac3|aac(?:[\.\-_ ]?[257][\.\-_ ]?[01])?|dts(?:[\.\-_ ]?hd)?(?:[\.\-_ ]?ma)?|truehd|atmos|flac|mp3|opus`)
+	importer.editionRegex = regexp.MustCompile(`(?i)director'?s[\.\-_ ]cut|extended(?:[\.\-_ ](?:edition|cut))?|unrated|uncut|remastered|imax|theatrical(?:[\.\-_ ]cut)?|final[\.\-_ ]cut|special[\.\-_ ]edition|collector'?s[\.\-_ ]edition|anniversary[\.\-_ ]edition|ultimate[\.\-_ ]edition|criterion`)
+	importer.languageRegex = regexp.MustCompile(`(?i)multi|truefrench|french|german|italian|spanish|vostfr|subbed|dubbed|dual[\.\-_ ]audio|english|japanese|korean|nordic|swedish|danish|dutch|russian|ita|ger|eng|jpn|vff`)
+	importer.releaseGroupRegex = regexp.MustCompile(`-\s*([A-Za-z0-9]+)\s*$`)
+	importer.multiEpisodeRegex = regexp.MustCompile(`(?i)s\d+e(\d+)((?:[\.\-_\s]*e\d+)+|-\d{1,3}\b)`)
+	importer.numberRegex = regexp.MustCompile(`\d+`)
+	importer.partRegex = regexp.MustCompile(`(?i)(cd|dis[ck]|part|pt)[\.\-_\s]*([1-9])`)
+	importer.episodeTokenRegex = regexp.MustCompile(`(?i)(?:^|[\.\-_\s])(\d{1,2}x\d{2}|\d{3,4})(?:[\.\-_\s]|$)`)
+}
+
+// parseEpisodes expands an episode list (S01E01E02) or range (S01E01-E03, S01E01-03) following the first episode.
+func (importer *NasImporter) parseEpisodes(file string, firstEpisode uint64) (episodes []uint64) {
+	episodes = []uint64{firstEpisode}
+	match := importer.multiEpisodeRegex.FindStringSubmatch(file)
+
+	if match == nil {
+		return
+	}
+
+	if episode, err := strconv.ParseUint(match[1], 10, 64); err != nil || episode != firstEpisode {
+		return
+	}
+
+	numbers := importer.numberRegex.FindAllString(match[2], -1)
+
+	if len(numbers) == 1 && strings.HasPrefix(match[2], "-") {
+		lastEpisode, err := strconv.ParseUint(numbers[0], 10, 64)
+
+		// Guard against a stray number being mistaken for the end of a huge range.
+		if err != nil || lastEpisode <= firstEpisode || lastEpisode - firstEpisode > maxEpisodeRange {
+			return
+		}
+
+		for episode := firstEpisode + 1; episode <= lastEpisode; episode++ {
+			episodes = append(episodes, episode)
+		}
+
+		return
+	}
+
+	for _, number := range numbers {
+		episode, err := strconv.ParseUint(number, 10, 64)
+
+		if err != nil || episode <= episodes[len(episodes) - 1] {
+			return []uint64{firstEpisode}
+		}
+
+		episodes = append(episodes, episode)
+	}
+
+	return
+}
+
+// compilePattern compiles a user-defined file name regex and checks that it has the named groups we need.
+func compilePattern(mediaType, pattern string, requiredGroups []string) (patternRegex *mapregexp.MapRegexp, err error) {
+	patternRegex, err = mapregexp.Compile(pattern)
+
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Invalid %v pattern %q: %v", mediaType, pattern, err))
+
+		return
+	}
+
+	groups := map[string]bool{}
+
+	for _, group := range patternRegex.SubexpNames() {
+		groups[group] = true
+	}
+
+	for _, group := range requiredGroups {
+		if !groups[group] {
+			err = errors.New(fmt.Sprintf("Invalid %v pattern %q: missing named group %q.", mediaType, pattern, group))
+
+			return
+		}
+	}
+
+	return
+}
+
+func compilePatterns(mediaType string, patterns []string, requiredGroups []string) (patternRegexes []*mapregexp.MapRegexp, err error) {
+	for _, pattern := range patterns {
+		patternRegex, err := compilePattern(mediaType, pattern, requiredGroups)
+
+		if err != nil {
+			return nil, err
+		}
+
+		patternRegexes = append(patternRegexes, patternRegex)
+	}
+
+	return
+}
+
+func (importer *NasImporter) compilePatterns() (err error) {
+	patterns := &importer.config.Patterns
+
+	if importer.tvPatterns, err = compilePatterns("tv", patterns.TV, []string{"name", "season", "episode", "ext"}); err != nil {
+		return
+	}
+
+	if importer.documentaryPatterns, err = compilePatterns("documentaries", patterns.Documentary, []string{"name", "ext"}); err != nil {
+		return
+	}
+
+	importer.moviePatterns, err = compilePatterns("movies", patterns.Movie, []string{"name", "ext"})
+
+	return
+}
+
+// releaseRegexes returns the file name regexes for a media type, most specific first.
+// User-defined patterns from the config are tried ahead of the built-in ones.
+func (importer *NasImporter) releaseRegexes(mediaType MediaType) []*mapregexp.MapRegexp {
+	tvRegexes := append([]*mapregexp.MapRegexp{}, importer.tvPatterns...)
+	tvRegexes = append(tvRegexes,
+		importer.tvShowRegex1,
+		importer.tvShowRegex2,
+		importer.dailyShowRegex,
+		importer.absoluteEpisodeRegex,
+		importer.specialRegex,
+		importer.tvShowRegex3,
+		importer.tvShowRegex4,
+	)
+
+	switch mediaType {
+		case TV:
+			return tvRegexes
+
+		case Documentary:
+			// Try the different documentary regexes in order of complexity as singleDocumentaryRegex will almost always match.
+			documentaryRegexes := append(append([]*mapregexp.MapRegexp{}, importer.documentaryPatterns...), tvRegexes...)
+
+			return append(documentaryRegexes, importer.yearDocumentaryRegex, importer.multiDocumentaryRegex, importer.singleDocumentaryRegex)
+
+		case Movie:
+			return append(append([]*mapregexp.MapRegexp{}, importer.moviePatterns...), importer.movieWithYearRegex, importer.movieWithoutYearRegex)
+	}
+
+	return nil
+}
+
+// ParseRelease parses a file name as the given media type.
+func (importer *NasImporter) ParseRelease(file string, mediaType MediaType) (release *ParsedRelease, err error) {
+	for _, releaseRegex := range importer.releaseRegexes(mediaType) {
+		fields := releaseRegex.FindStringSubmatchMap(file)
+
+		if fields == nil {
+			continue
+		}
+
+		if releaseRegex == importer.specialRegex && !importer.specialFields(fields) {
+			continue
+		}
+
+		// "Planet Earth - 2006.mkv" is more likely dated than the 2006th episode or S20E06. A documentary takes the
+		// year, and for a TV show the name isn't an episode at all.
+		if importer.isYearEpisode(releaseRegex, file, fields) {
+			if yearFields := importer.movieWithYearRegex.FindStringSubmatchMap(file); yearFields != nil {
+				if mediaType != Documentary {
+					continue
+				}
+
+				fields = yearFields
+			}
+		}
+
+		release = importer.releaseFromFields(file, mediaType, fields)
+
+		return
+	}
+
+	switch mediaType {
+		case TV:
+			err = errors.New("Not a TV show")
+
+		case Documentary:
+			err = errors.New("Not a documentary")
+
+		default:
+			err = errors.New("Not a movie")
+	}
+
+	return
+}
+
+// releaseFromFields builds the release for a file from the fields its name matched.
+func (importer *NasImporter) releaseFromFields(file string, mediaType MediaType, fields map[string]string) (release *ParsedRelease) {
+	release = importer.parseReleaseFields(fields)
+
+	if release.HasEpisode() {
+		release.Episodes = importer.parseEpisodes(file, release.Episode())
+	}
+
+	if mediaType == Movie {
+		importer.parseMoviePart(release)
+	}
+
+	return
+}
+
+// isYearEpisode reports whether the episode a regex read from a file name could be a year, either as an absolute
+// episode or as a season and episode written without a separator.
+func (importer *NasImporter) isYearEpisode(releaseRegex *mapregexp.MapRegexp, file string, fields map[string]string) bool {
+	switch releaseRegex {
+		case importer.absoluteEpisodeRegex:
+			return isYear(fields["absolute"])
+
+		case importer.tvShowRegex3, importer.tvShowRegex4:
+			number := fields["season"] + fields["episode"]
+
+			return isYear(number) && strings.Contains(file, number)
+	}
+
+	return false
+}
+
+// isYear reports whether a number is a plausible release year, 19xx or 20xx.
+func isYear(number string) bool {
+	return len(number) == 4 && (strings.HasPrefix(number, "19") || strings.HasPrefix(number, "20"))
+}
+
+// specialFields checks a match of specialRegex, where "Special" may be part of something else: a show name followed by
+// an episode number ("Law.and.Order.Special.Victims.Unit.2x05.mkv"), an edition ("Aliens.1986.Special.Edition.mkv")
+// or a title with only a year after it ("The.Special.2020.mkv"). A year ending the show name, as in
+// "Doctor.Who.2005.Special.Christmas.mkv", is moved to the year field.
+func (importer *NasImporter) specialFields(fields map[string]string) bool {
+	special, _ := importer.splitTags(fields["special"])
+	specialWords := importer.wordRegex.FindAllString(special, -1)
+
+	if len(specialWords) == 0 || strings.EqualFold(specialWords[0], "edition") || (len(specialWords) == 1 && isYear(specialWords[0])) {
+		return false
+	}
+
+	if importer.isNumberedEpisode(fields["special"]) {
+		return false
+	}
+
+	if nameFields := importer.directoryRegex.FindStringSubmatchMap(fields["name"]); nameFields != nil && nameFields["year"] != "" {
+		fields["name"] = nameFields["name"]
+		fields["year"] = nameFields["year"]
+	}
+
+	// A special belongs to a show, which needs more of a name than an article.
+	showWords := normalizeTitle(fields["name"])
+
+	return len(showWords) > 1 || (len(showWords) == 1 && !articles[showWords[0]])
+}
+
+// isNumberedEpisode reports whether a special's title ends in an episode number, which makes it a show whose name
+// contains "Special", e.g. "Law.and.Order.Special.Victims.Unit.2x05.mkv". Years don't count.
+func (importer *NasImporter) isNumberedEpisode(special string) bool {
+	title, _ := importer.splitTags(special)
+
+	for _, match := range importer.episodeTokenRegex.FindAllStringSubmatch(title, -1) {
+		if !isYear(match[1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitTags splits text at its first release tag. Names without a year or episode swallow every release tag, so
+// they need cutting before they can be used as a title.
+func (importer *NasImporter) splitTags(text string) (before, after string) {
+	cut := len(text)
+
+	for _, tagRegex := range []*regexp.Regexp{importer.resolutionRegex, importer.releaseSourceRegex, importer.videoCodecRegex, importer.audioCodecRegex, importer.editionRegex} {
+		if spans := findTags(tagRegex, text); len(spans) > 0 && spans[0][0] > 0 && spans[0][0] < cut {
+			cut = spans[0][0]
+		}
+	}
+
+	return text[: cut], text[cut :]
+}
+
+func (importer *NasImporter) parseReleaseFields(fields map[string]string) (release *ParsedRelease) {
+	release = &ParsedRelease{Ext: fields["ext"], Other: fields["other"]}
+	name := fields["name"]
+	tags := fields["other"]
+
+	if season, err := strconv.ParseUint(fields["season"], 10, 64); err == nil {
+		release.HasSeason = true
+		release.Season = season
+	}
+
+	if episode, err := strconv.ParseUint(fields["episode"], 10, 64); err == nil {
+		release.Episodes = []uint64{episode}
+	}
+
+	release.Year, _ = strconv.ParseUint(fields["year"], 10, 64)
+	release.AbsoluteEpisode, _ = strconv.ParseUint(fields["absolute"], 10, 64)
+	release.ReleaseGroup = fields["group"]
+
+	if airDate := fields["airdate"]; airDate != "" {
+		release.AirDate = strings.Join(importer.numberRegex.FindAllString(airDate, -1), "-")
+	}
+
+	// Only a name the regex didn't end at a year or episode runs on into the release tags.
+	if release.Year == 0 && !release.HasEpisode() && release.AirDate == "" && release.AbsoluteEpisode == 0 {
+		var nameTags string
+
+		name, nameTags = importer.splitTags(name)
+		tags = nameTags + " " + tags
+	}
+
+	release.Title = strings.Join(importer.wordRegex.FindAllString(name, -1), " ")
+
+	if special := fields["special"]; special != "" {
+		special, specialTags := importer.splitTags(special)
+		tags = tags + " " + specialTags
+		release.HasSeason = true
+		release.Season = 0
+		release.SpecialTitle = strings.Join(importer.wordRegex.FindAllString(special, -1), " ")
+	}
+
+	// Remove each tag once found so that its parts can't be mistaken for a release group.
+	untagged := []byte(tags)
+
+	for _, tag := range []struct {
+		tagRegex *regexp.Regexp
+		value *string
+	}{
+		{importer.resolutionRegex, &release.Resolution},
+		{importer.releaseSourceRegex, &release.Source},
+		{importer.videoCodecRegex, &release.VideoCodec},
+		{importer.audioCodecRegex, &release.AudioCodec},
+		{importer.editionRegex, &release.Edition},
+	} {
+		spans := findTags(tag.tagRegex, tags)
+
+		if len(spans) == 0 {
+			continue
+		}
+
+		*tag.value = canonicalTag(tags[spans[0][0] : spans[0][1]])
+
+		if tag.tagRegex == importer.resolutionRegex && *tag.value != "2160p" {
+			*tag.value = strings.ToLower(*tag.value)
+		}
+
+		for _, span := range spans {
+			for index := span[0]; index < span[1]; index++ {
+				untagged[index] = ' '
+			}
+		}
+	}
+
+	for _, span := range findTags(importer.languageRegex, tags) {
+		release.Languages = append(release.Languages, canonicalTag(tags[span[0] : span[1]]))
+	}
+
+	if match := importer.releaseGroupRegex.FindStringSubmatch(string(untagged)); match != nil && release.ReleaseGroup == "" {
+		release.ReleaseGroup = match[1]
+	}
+
+	return
+}
+
+// parseDirectory reads a title and year from a directory name such as "Movie (1999)" or "Show.Name.2019.1080p-GRP".
+func (importer *NasImporter) parseDirectory(dir string) (fields map[string]string) {
+	name := filepath.Base(dir)
+
+	if name == "." || name == string(filepath.Separator) {
+		return nil
+	}
+
+	return importer.directoryRegex.FindStringSubmatchMap(name)
+}
+
+// isSoleVideo reports whether path is the only video file in its directory, as in a directory made for one movie.
+func (importer *NasImporter) isSoleVideo(path string) bool {
+	files, _, err := importer.getFilesDirs(filepath.Dir(path))
+
+	if err != nil {
+		return false
+	}
+
+	videos := 0
+
+	for _, file := range files {
+		if importer.isVideoFile(file) {
+			videos++
+		}
+	}
+
+	return videos == 1 && pathExists(path)
+}
+
+// parseSeasonDir reads the season number from a directory name such as "Season 2", "S02" or "Specials".
+func (importer *NasImporter) parseSeasonDir(name string) (season string, ok bool) {
+	match := importer.seasonDirRegex.FindStringSubmatch(name)
+
+	if match == nil {
+		return "", false
+	}
+
+	if match[4] == "" {
+		return "0", true
+	}
+
+	return match[4], true
+}
+
+// ParsePath parses a file as the given media type, using the names of its parent directories where the file
+// name alone is ambiguous, e.g. "Show Name/Season 2/03 - Title.mkv", "Show Name/01x03.mkv" or
+// "Movie (1999)/movie.mkv".
+func (importer *NasImporter) ParsePath(path string, mediaType MediaType) (release *ParsedRelease, err error) {
+	file := filepath.Base(path)
+	dir := filepath.Dir(path)
+	season, hasSeasonDir := importer.parseSeasonDir(filepath.Base(dir))
+
+	if hasSeasonDir {
+		dir = filepath.Dir(dir)
+	}
+
+	dirFields := importer.parseDirectory(dir)
+
+	// A bare season and episode takes the show from the directory, above the season directory if there is one.
+	if mediaType != Movie && dirFields != nil {
+		if fields := importer.bareSeasonEpisodeRegex.FindStringSubmatchMap(file); fields != nil {
+			fields["name"] = dirFields["name"]
+			fields["year"] = dirFields["year"]
+			release = importer.parseReleaseFields(fields)
+
+			return
+		}
+	}
+
+	// So does a bare episode number inside a season directory.
+	if mediaType != Movie && hasSeasonDir && dirFields != nil {
+		if fields := importer.bareEpisodeRegex.FindStringSubmatchMap(file); fields != nil {
+			fields["name"] = dirFields["name"]
+			fields["year"] = dirFields["year"]
+			fields["season"] = season
+			release = importer.parseReleaseFields(fields)
+
+			return
+		}
+	}
+
+	release, err = importer.ParseRelease(file, mediaType)
+
+	if err != nil {
+		return
+	}
+
+	if hasSeasonDir && !release.HasSeason && release.HasEpisode() {
+		release.HasSeason = true
+		release.Season, _ = strconv.ParseUint(season, 10, 64)
+	}
+
+	if release.Year != 0 || dirFields == nil {
+		return
+	}
+
+	dirRelease := importer.parseReleaseFields(dirFields)
+
+	if dirRelease.Year == 0 {
+		return
+	}
+
+	switch {
+		// Movies are usually filed as "Movie (1999)/movie.mkv", the directory names them better than the file, which
+		// may well be obfuscated. A directory holding other videos too, such as "Movies 2020", names a collection
+		// unless it shares words with the file.
+		case (mediaType == Movie || (mediaType == Documentary && !release.IdentifiesEpisode())) && (titlesOverlap(release.Title, dirRelease.Title) || importer.isSoleVideo(path)):
+			release.Title = dirRelease.Title
+			release.Year = dirRelease.Year
+
+		// Otherwise only borrow the year from a directory naming the same show.
+		case strings.EqualFold(release.Title, dirRelease.Title):
+			release.Year = dirRelease.Year
+	}
+
+	return
+}
@@ -0,0 +1,208 @@
+package nasimporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// parseMoviePart finds the part number of a movie split over several files, e.g. "Movie.1999.CD1.avi".
+// CD and disc markers may appear anywhere, but "part" only after the title so "Movie Part 2 (2011)" keeps its name.
+func (importer *NasImporter) parseMoviePart(release *ParsedRelease) {
+	if spans := findTags(importer.partRegex, release.Other); len(spans) > 0 {
+		match := importer.partRegex.FindStringSubmatch(release.Other[spans[0][0] : spans[0][1]])
+		release.Part = uint64(match[2][0] - '0')
+
+		return
+	}
+
+	for _, span := range findTags(importer.partRegex, release.Title) {
+		match := importer.partRegex.FindStringSubmatch(release.Title[span[0] : span[1]])
+
+		if kind := strings.ToLower(match[1]); kind == "part" || kind == "pt" || span[0] == 0 {
+			continue
+		}
+
+		release.Part = uint64(match[2][0] - '0')
+		release.Title = strings.TrimSpace(release.Title[: span[0]])
+
+		return
+	}
+}
+
+// groupParts finds the multi-part movies among files. Each set is keyed by the path of its first part and lists
+// every part in order, the other parts are mapped to their first part in followers.
+func (importer *NasImporter) groupParts(files []ImportResult) (sets map[string][]string, followers map[string]string) {
+	type part struct {
+		path string
+		number uint64
+	}
+
+	candidates := map[string][]part{}
+
+	for _, file := range files {
+		if file.Err != nil {
+			continue
+		}
+
+		release, err := importer.ParsePath(file.Path, Movie)
+
+		if err != nil || release.Part == 0 {
+			continue
+		}
+
+		key := fmt.Sprintf("%v|%v|%v|%v", filepath.Dir(file.Path), strings.ToLower(release.Title), release.Year, strings.ToLower(release.Ext))
+		candidates[key] = append(candidates[key], part{path: file.Path, number: release.Part})
+	}
+
+	sets = map[string][]string{}
+	followers = map[string]string{}
+
+	for _, parts := range candidates {
+		if len(parts) < 2 {
+			continue
+		}
+
+		// The part listed first leads the set, so it is imported before the parts which may be joined to it.
+		firstPath := parts[0].path
+
+		sort.Slice(parts, func(i, j int) bool {
+			return parts[i].number < parts[j].number
+		})
+
+		paths := []string{}
+		complete := true
+
+		for index, part := range parts {
+			if part.number != uint64(index + 1) {
+				complete = false
+
+				break
+			}
+
+			paths = append(paths, part.path)
+		}
+
+		// Leave incomplete or duplicated sets alone rather than join the wrong files.
+		if !complete {
+			continue
+		}
+
+		sets[firstPath] = paths
+
+		for _, path := range paths {
+			if path != firstPath {
+				followers[path] = firstPath
+			}
+		}
+	}
+
+	return
+}
+
+func (importer *NasImporter) joinUsingMKVMerge(paths []string, outPath string) (err error) {
+	// mkvmerge appends files joined with "+": mkvmerge -o out part1 + part2.
+	args := []string{"-o", outPath, paths[0]}
+
+	for _, path := range paths[1 :] {
+		args = append(args, "+", path)
+	}
+
+	cmd := exec.Command(importer.config.MatroskaMuxers.MKVMerge, args...)
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err = cmd.Run(); err != nil {
+		err = errors.New(importer.config.MatroskaMuxers.MKVMerge + " exited with error: " + strings.TrimSpace(stdout.String()))
+	}
+
+	return
+}
+
+func (importer *NasImporter) joinUsingFFMPEG(paths []string, outPath string) (err error) {
+	listFile, err := ioutil.TempFile("", "nasimport-concat")
+
+	if err != nil {
+		return
+	}
+
+	defer os.Remove(listFile.Name())
+
+	for _, path := range paths {
+		fmt.Fprintf(listFile, "file '%v'\n", strings.Replace(path, "'", `'\''`, -1))
+	}
+
+	if err = listFile.Close(); err != nil {
+		return
+	}
+
+	cmd := exec.Command(importer.config.MatroskaMuxers.FFMPEG, "-fflags", "+genpts", "-f", "concat", "-safe", "0", "-i", listFile.Name(), "-codec", "copy", "-y", outPath)
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err = cmd.Run(); err != nil {
+		err = errors.New(importer.config.MatroskaMuxers.FFMPEG + " exited with error: " + strings.TrimSpace(stderr.String()))
+	}
+
+	return
+}
+
+// importParts joins the parts of a multi-part movie into a single Matroska file.
+func (importer *NasImporter) importParts(paths []string, outPath, providerId string) (method ImportMethod, err error) {
+	if err = checkDestination(outPath); err != nil {
+		if importer.dryRun && errors.Is(err, ErrDestinationExists) {
+			fmt.Fprintf(importer.out, "Plan: skip %v, %v already exists.\n", strings.Join(paths, " + "), outPath)
+
+			return SkipMethod, nil
+		}
+
+		return
+	}
+
+	if importer.dryRun {
+		method = FFMPEGConcatMethod
+
+		if _, err := exec.LookPath(importer.config.MatroskaMuxers.MKVMerge); err == nil {
+			method = MKVMergeAppendMethod
+		}
+
+		fmt.Fprintf(importer.out, "Plan: %v %v -> %v\n", method, strings.Join(paths, " + "), outPath)
+
+		return method, nil
+	}
+
+	if err = os.MkdirAll(filepath.Dir(outPath), os.ModeDir | 0755); err != nil {
+		return
+	}
+
+	method = MKVMergeAppendMethod
+	err = importer.joinUsingMKVMerge(paths, outPath)
+
+	if err != nil {
+		fmt.Fprintln(importer.out, err)
+
+		method = FFMPEGConcatMethod
+		err = importer.joinUsingFFMPEG(paths, outPath)
+
+		if err != nil {
+			err = fmt.Errorf("%w: %v", ErrMuxFailed, err)
+
+			return
+		}
+	}
+
+	// Undo deletes the joined file only while every part is still around.
+	importer.recordImport(JournalEntry{Source: paths[0], Parts: paths, Destination: outPath, Method: method, ProviderId: providerId})
+
+	return
+}
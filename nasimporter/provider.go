@@ -0,0 +1,192 @@
+package nasimporter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"github.com/garfunkel/go-tvdb"
+	"github.com/StalkR/imdb"
+)
+
+// MetadataProvider is a source of TV series, episode and movie metadata. Searches finding nothing return no results,
+// and lookups of unknown IDs ErrNotFound.
+type MetadataProvider interface {
+	SearchSeries(name string, maxResults int) (seriesList tvdb.SeriesList, err error)
+	SearchTitles(name string) (titles []imdb.Title, err error)
+	GetSeasons(series *tvdb.Series) (seasons map[uint64][]*tvdb.Episode, err error)
+	GetSeries(id uint64) (series tvdb.Series, err error)
+	GetSeriesByIMDBId(id string) (series tvdb.Series, err error)
+	GetTitle(id string) (title imdb.Title, err error)
+}
+
+// OnlineProvider looks up metadata on TheTVDB and IMDb.
+type OnlineProvider struct {
+	imdbClient http.Client
+}
+
+func NewOnlineProvider() *OnlineProvider {
+	return &OnlineProvider{}
+}
+
+func (provider *OnlineProvider) SearchSeries(name string, maxResults int) (seriesList tvdb.SeriesList, err error) {
+	seriesList, err = tvdb.SearchSeries(name, maxResults)
+
+	return
+}
+
+func (provider *OnlineProvider) SearchTitles(name string) (titles []imdb.Title, err error) {
+	titles, err = imdb.SearchTitle(&provider.imdbClient, name)
+
+	return
+}
+
+func (provider *OnlineProvider) GetSeasons(series *tvdb.Series) (seasons map[uint64][]*tvdb.Episode, err error) {
+	if series.Seasons == nil {
+		if err = series.GetDetail(); err != nil {
+			return
+		}
+	}
+
+	seasons = series.Seasons
+
+	return
+}
+
+func (provider *OnlineProvider) GetSeries(id uint64) (series tvdb.Series, err error) {
+	fullSeries, err := tvdb.GetSeriesByID(id)
+
+	if err != nil {
+		return
+	}
+
+	series = *fullSeries
+
+	return
+}
+
+func (provider *OnlineProvider) GetSeriesByIMDBId(id string) (series tvdb.Series, err error) {
+	series, err = tvdb.GetSeriesByIMDBId(id)
+
+	return
+}
+
+func (provider *OnlineProvider) GetTitle(id string) (title imdb.Title, err error) {
+	fullTitle, err := imdb.NewTitle(&provider.imdbClient, id)
+
+	if err != nil {
+		return
+	}
+
+	title = *fullTitle
+
+	return
+}
+
+// MemoryProvider serves metadata from memory, so imports can run without network access.
+type MemoryProvider struct {
+	Series []tvdb.Series
+	Titles []imdb.Title
+	Seasons map[uint64]map[uint64][]*tvdb.Episode
+	IMDBSeriesIds map[string]uint64
+}
+
+func NewMemoryProvider() *MemoryProvider {
+	return &MemoryProvider{
+		Seasons: map[uint64]map[uint64][]*tvdb.Episode{},
+		IMDBSeriesIds: map[string]uint64{},
+	}
+}
+
+func (provider *MemoryProvider) matches(name, query string) bool {
+	name = strings.ToLower(name)
+
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(name, word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (provider *MemoryProvider) SearchSeries(name string, maxResults int) (seriesList tvdb.SeriesList, err error) {
+	for _, series := range provider.Series {
+		if len(seriesList.Series) >= maxResults {
+			break
+		}
+
+		if provider.matches(series.SeriesName, name) {
+			seriesList.Series = append(seriesList.Series, series)
+		}
+	}
+
+	return
+}
+
+func (provider *MemoryProvider) SearchTitles(name string) (titles []imdb.Title, err error) {
+	for _, title := range provider.Titles {
+		if provider.matches(title.Name, name) {
+			titles = append(titles, title)
+		}
+	}
+
+	return
+}
+
+func (provider *MemoryProvider) GetSeasons(series *tvdb.Series) (seasons map[uint64][]*tvdb.Episode, err error) {
+	seasons, ok := provider.Seasons[series.Id]
+
+	if !ok {
+		err = errors.New(fmt.Sprintf("No episodes known for series %v.", series.Id))
+
+		return
+	}
+
+	series.Seasons = seasons
+
+	return
+}
+
+func (provider *MemoryProvider) GetSeries(id uint64) (series tvdb.Series, err error) {
+	for _, series = range provider.Series {
+		if series.Id == id {
+			return
+		}
+	}
+
+	series = tvdb.Series{}
+	err = fmt.Errorf("%w: no series with ID %v", ErrNotFound, id)
+
+	return
+}
+
+func (provider *MemoryProvider) GetSeriesByIMDBId(id string) (series tvdb.Series, err error) {
+	seriesId, ok := provider.IMDBSeriesIds[id]
+
+	if ok {
+		for _, series = range provider.Series {
+			if series.Id == seriesId {
+				return
+			}
+		}
+	}
+
+	series = tvdb.Series{}
+	err = fmt.Errorf("%w: no series with IMDb ID %v", ErrNotFound, id)
+
+	return
+}
+
+func (provider *MemoryProvider) GetTitle(id string) (title imdb.Title, err error) {
+	for _, title = range provider.Titles {
+		if title.ID == id {
+			return
+		}
+	}
+
+	title = imdb.Title{}
+	err = fmt.Errorf("%w: no title with IMDb ID %v", ErrNotFound, id)
+
+	return
+}
@@ -0,0 +1,112 @@
+package nasimporter
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/StalkR/imdb"
+)
+
+type Candidate struct {
+	Rank int `json:"rank"`
+	Value string `json:"value"`
+	Year int `json:"year,omitempty"`
+	Score float64 `json:"score"`
+	Source string `json:"source"`
+	ProviderId string `json:"provider_id"`
+	PreviouslyChosen bool `json:"previously_chosen,omitempty"`
+}
+
+// FileReport is the machine-readable summary of a single file, emitted with -output json.
+type FileReport struct {
+	Path string `json:"path"`
+	TVShowFields *ParsedRelease `json:"tv_show_fields,omitempty"`
+	DocumentaryFields *ParsedRelease `json:"documentary_fields,omitempty"`
+	MovieFields *ParsedRelease `json:"movie_fields,omitempty"`
+	Candidates []Candidate `json:"candidates"`
+	Match *Candidate `json:"match,omitempty"`
+	Outcome string `json:"outcome"`
+	Destination string `json:"destination,omitempty"`
+	Existing string `json:"existing,omitempty"`
+	Method ImportMethod `json:"method,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func (source MediaSource) String() string {
+	switch source {
+		case TVTVDB:
+			return "tv_tvdb"
+		case DocumentaryTVDB:
+			return "documentary_tvdb"
+		case DocumentaryIMDB:
+			return "documentary_imdb"
+		case MovieIMDB:
+			return "movie_imdb"
+		case TVLocal:
+			return "tv_local"
+		case DocumentaryLocal:
+			return "documentary_local"
+	}
+
+	return "unknown"
+}
+
+func newCandidate(rank int, scoreItem ScoreItem) (candidate Candidate) {
+	candidate = Candidate{
+		Rank: rank,
+		Value: scoreItem.value,
+		Score: scoreItem.score,
+		Source: scoreItem.source.String(),
+		ProviderId: getProviderId(scoreItem.data),
+		PreviouslyChosen: scoreItem.previouslyChosen,
+	}
+
+	if title, ok := scoreItem.data.(imdb.Title); ok {
+		candidate.Year = title.Year
+	}
+
+	return
+}
+
+func newFileReport(lookup *fileLookup) (report FileReport) {
+	report = FileReport{
+		Path: lookup.path,
+		TVShowFields: lookup.tvShowRelease,
+		DocumentaryFields: lookup.documentaryRelease,
+		MovieFields: lookup.movieRelease,
+		Candidates: []Candidate{},
+	}
+
+	for index, scoreItem := range lookup.absoluteOrder {
+		report.Candidates = append(report.Candidates, newCandidate(index + 1, scoreItem))
+	}
+
+	return
+}
+
+func (importer *NasImporter) writeReport(report FileReport, err error) {
+	switch {
+		case err == nil && importer.dryRun:
+			report.Outcome = "planned"
+
+		case err == nil:
+			report.Outcome = "imported"
+
+		case errors.Is(err, ErrQueuedForReview):
+			report.Outcome = "queued"
+
+		default:
+			report.Outcome = "failed"
+	}
+
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	reportBytes, err := json.Marshal(report)
+
+	if err != nil {
+		return
+	}
+
+	importer.reportOut.Write(append(reportBytes, '\n'))
+}
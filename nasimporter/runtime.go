@@ -0,0 +1,183 @@
+package nasimporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/garfunkel/go-tvdb"
+	"github.com/StalkR/imdb"
+)
+
+// runtimeBonus is added to the score of a candidate whose runtime fits the file's duration, and taken from one
+// whose runtime is far off.
+const runtimeBonus = 0.1
+
+// runtimeCandidates is how many of the best candidates have their runtime compared, as it may need a lookup each.
+const runtimeCandidates = 10
+
+// ffprobePath finds ffprobe, which is installed alongside ffmpeg. ffmpeg may be a path or a command found on PATH.
+func (importer *NasImporter) ffprobePath() (ffprobe string, err error) {
+	ffmpeg, err := exec.LookPath(importer.config.MatroskaMuxers.FFMPEG)
+
+	if err != nil {
+		return
+	}
+
+	ffprobe, err = exec.LookPath(filepath.Join(filepath.Dir(ffmpeg), "ffprobe" + filepath.Ext(ffmpeg)))
+
+	return
+}
+
+// probeDuration reads the playing time of a video file with ffprobe.
+func probeDuration(ffprobe, path string) (duration time.Duration, err error) {
+	cmd := exec.Command(ffprobe, "-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path)
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err = cmd.Run(); err != nil {
+		err = errors.New(ffprobe + " exited with error: " + strings.TrimSpace(stderr.String()))
+
+		return
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(stdout.String()), 64)
+
+	if err != nil {
+		err = errors.New(fmt.Sprintf("%v returned an invalid duration for %v.", ffprobe, path))
+
+		return
+	}
+
+	duration = time.Duration(seconds * float64(time.Second))
+
+	return
+}
+
+// parseRuntime reads a provider runtime, either minutes as TheTVDB gives them ("45") or a duration as IMDb does
+// ("2h22m").
+func parseRuntime(text string) (runtime time.Duration, ok bool) {
+	text = strings.TrimSpace(text)
+
+	if minutes, err := strconv.ParseUint(text, 10, 64); err == nil {
+		runtime = time.Duration(minutes) * time.Minute
+	} else if runtime, err = time.ParseDuration(text); err != nil {
+		return 0, false
+	}
+
+	return runtime, runtime > 0
+}
+
+// candidateRuntime finds how long a candidate should run, which for a series is its typical episode length. Warnings
+// go to output.
+func (importer *NasImporter) candidateRuntime(output io.Writer, scoreItem ScoreItem) (runtime time.Duration, ok bool) {
+	switch data := scoreItem.data.(type) {
+		case tvdb.Series:
+			if data.Runtime == "" {
+				if series, err := importer.getTVDBSeries(output, data.Id); err == nil {
+					data = series
+				}
+			}
+
+			return parseRuntime(data.Runtime)
+
+		case imdb.Title:
+			if data.Duration == "" {
+				if title, err := importer.getIMDBTitle(output, data.ID); err == nil {
+					data = title
+				}
+			}
+
+			return parseRuntime(data.Duration)
+	}
+
+	return
+}
+
+// runtimeAdjustment scores how well a file's duration fits a candidate's runtime. Series runtimes are broadcast slots
+// including adverts, so episodes may well be shorter.
+func runtimeAdjustment(duration, runtime time.Duration, episodic bool) float64 {
+	ratio := duration.Seconds() / runtime.Seconds()
+	low, high := 0.9, 1.1
+
+	if episodic {
+		low = 0.6
+	}
+
+	switch {
+		case ratio >= low && ratio <= high:
+			return runtimeBonus
+
+		// A movie is far longer than an episode and a remake may be much longer than the original.
+		case ratio < low / 2 || ratio > high * 2:
+			return -runtimeBonus
+	}
+
+	return 0
+}
+
+// scoreRuntimes adjusts the best candidates' scores by comparing their runtimes with the file's duration, summed over
+// every part of a multi-part movie. Nothing changes if the duration can't be probed, and the output says why.
+func (importer *NasImporter) scoreRuntimes(lookup *fileLookup) {
+	ffprobe, err := importer.ffprobePath()
+
+	if err != nil {
+		fmt.Fprintf(&lookup.output, "Not comparing runtimes, ffprobe wasn't found: %v\n", err)
+
+		return
+	}
+
+	paths := lookup.parts
+
+	if len(paths) == 0 {
+		paths = []string{lookup.path}
+	}
+
+	duration := time.Duration(0)
+
+	for _, path := range paths {
+		partDuration, err := probeDuration(ffprobe, path)
+
+		if err != nil {
+			fmt.Fprintf(&lookup.output, "Not comparing runtimes: %v\n", err)
+
+			return
+		}
+
+		duration += partDuration
+	}
+
+	fmt.Fprintf(&lookup.output, "Duration: %v\n", duration.Round(time.Second))
+
+	for index := range lookup.absoluteOrder {
+		if index >= runtimeCandidates {
+			break
+		}
+
+		scoreItem := &lookup.absoluteOrder[index]
+		runtime, ok := importer.candidateRuntime(&lookup.output, *scoreItem)
+
+		if !ok {
+			continue
+		}
+
+		episodic := scoreItem.source == TVTVDB || scoreItem.source == DocumentaryTVDB
+
+		// A multi-episode file runs as long as all of its episodes.
+		if release := lookup.release(scoreItem.source.mediaType()); episodic && release != nil && len(release.Episodes) > 1 {
+			runtime *= time.Duration(len(release.Episodes))
+		}
+
+		scoreItem.score += runtimeAdjustment(duration, runtime, episodic)
+	}
+
+	sort.Sort(lookup.absoluteOrder)
+}
@@ -0,0 +1,195 @@
+package nasimporter
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"github.com/arbovm/levenshtein"
+)
+
+// yearBonus is added to the similarity of a candidate released in the same year as the file.
+const yearBonus = 0.1
+
+var accentFolds = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ą': "a",
+	'æ': "ae",
+	'ç': "c", 'ć': "c", 'č': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ı': "i",
+	'ł': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'œ': "oe",
+	'ř': "r",
+	'ś': "s", 'š': "s", 'ş': "s",
+	'ß': "ss",
+	'ť': "t", 'ţ': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+	'ý': "y", 'ÿ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+	'þ': "th",
+}
+
+var articles = map[string]bool{
+	"the": true,
+	"a": true,
+	"an": true,
+}
+
+// normalizeTitle splits a title into comparable words: case and accents are folded, punctuation is dropped, "&" is
+// read as "and", and a leading article, or one moved to the end after a comma, is removed so that "The Office" and
+// "Office, The" agree. Other trailing articles are words, as in "Plan A".
+func normalizeTitle(title string) (words []string) {
+	var folded strings.Builder
+
+	title = strings.ToLower(title)
+	trailingArticle := false
+
+	if comma := strings.LastIndex(title, ","); comma >= 0 {
+		trailingArticle = articles[strings.TrimSpace(title[comma + 1 :])]
+	}
+
+	for _, char := range title {
+		switch {
+			case accentFolds[char] != "":
+				folded.WriteString(accentFolds[char])
+
+			case char == '&':
+				folded.WriteString(" and ")
+
+			// Apostrophes join words rather than split them, "Director's" is "directors".
+			case char == '\'' || char == '’':
+
+			case unicode.IsLetter(char) || unicode.IsDigit(char):
+				folded.WriteRune(char)
+
+			default:
+				folded.WriteRune(' ')
+		}
+	}
+
+	words = strings.Fields(folded.String())
+
+	if trailingArticle && len(words) > 1 {
+		words = words[: len(words) - 1]
+	}
+
+	if len(words) > 1 && articles[words[0]] {
+		words = words[1 :]
+	}
+
+	return
+}
+
+// titlesOverlap tells whether two titles share a word once normalized.
+func titlesOverlap(title1, title2 string) bool {
+	words := map[string]bool{}
+
+	for _, word := range normalizeTitle(title1) {
+		words[word] = true
+	}
+
+	for _, word := range normalizeTitle(title2) {
+		if words[word] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stringRatio is 1 for identical strings, falling towards 0 as the edit distance approaches their length.
+func stringRatio(string1, string2 string) float64 {
+	length := math.Max(float64(len(string1)), float64(len(string2)))
+
+	if length == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshtein.Distance(string1, string2)) / length
+}
+
+// TitleSimilarity scores how well a candidate title matches a title read from a file name. It is the better of the
+// share of words the two have in common and the edit-distance ratio of their sorted words, so word order and small
+// typos are both tolerated. 1 means identical once normalized, and candidates from the same year get a bonus.
+func TitleSimilarity(title string, year uint64, candidate string, candidateYear uint64) (score float64) {
+	titleWords := normalizeTitle(title)
+	candidateWords := normalizeTitle(candidate)
+
+	if len(titleWords) == 0 || len(candidateWords) == 0 {
+		return 0
+	}
+
+	titleSet := map[string]bool{}
+
+	for _, word := range titleWords {
+		titleSet[word] = true
+	}
+
+	candidateSet := map[string]bool{}
+	sharedWords := 0
+
+	for _, word := range candidateWords {
+		if titleSet[word] && !candidateSet[word] {
+			sharedWords++
+		}
+
+		candidateSet[word] = true
+	}
+
+	score = 2 * float64(sharedWords) / float64(len(titleSet) + len(candidateSet))
+	sort.Strings(titleWords)
+	sort.Strings(candidateWords)
+
+	if ratio := stringRatio(strings.Join(titleWords, " "), strings.Join(candidateWords, " ")); ratio > score {
+		score = ratio
+	}
+
+	if year != 0 && candidateYear != 0 {
+		switch {
+			case year == candidateYear:
+				score += yearBonus
+
+			// Release years are often off by one from first air dates.
+			case year == candidateYear + 1 || year + 1 == candidateYear:
+				score += yearBonus / 2
+		}
+	}
+
+	return
+}
+
+// firstAiredYear reads the year from a TheTVDB date such as "2005-03-24".
+func firstAiredYear(firstAired string) (year uint64) {
+	if len(firstAired) >= 4 {
+		year, _ = strconv.ParseUint(firstAired[: 4], 10, 64)
+	}
+
+	return
+}
+
+// getSimilarityOrder ranks local directory names against a release, best first. Directory names may carry a year,
+// e.g. "Show (2005)".
+func (importer *NasImporter) getSimilarityOrder(candidates []string, release *ParsedRelease) (order ScoreItems) {
+	order = make(ScoreItems, 0, len(candidates))
+
+	for _, candidate := range candidates {
+		title := candidate
+		year := uint64(0)
+
+		if fields := importer.directoryRegex.FindStringSubmatchMap(candidate); fields != nil {
+			title = fields["name"]
+			year, _ = strconv.ParseUint(fields["year"], 10, 64)
+		}
+
+		order = append(order, ScoreItem{value: candidate, score: TitleSimilarity(release.Title, release.Year, title, year)})
+	}
+
+	sort.Sort(order)
+
+	return
+}
@@ -0,0 +1,151 @@
+package nasimporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const (
+	defaultTVEpisodeTemplate = `{{.Series}}/{{if .Season}}Season {{pad .Season}}{{else}}Specials{{end}}/{{.Series}} S{{pad .Season}}E{{pad .Episode}}{{if .LastEpisode}}-E{{pad .LastEpisode}}{{end}}{{if .AirDate}} ({{.AirDate}}){{end}}{{if .EpisodeTitle}} - {{.EpisodeTitle}}{{end}}.{{.Ext}}`
+	defaultDocumentarySeriesTemplate = defaultTVEpisodeTemplate
+	defaultDocumentaryWithYearTemplate = `{{.Title}} ({{.Year}}).{{.Ext}}`
+	defaultDocumentaryTemplate = `{{.Title}}.{{.Ext}}`
+	defaultMovieTemplate = `{{.Title}} ({{.Year}}){{if .Edition}} {edition-{{.Edition}}}{{end}}.{{.Ext}}`
+)
+
+// PathFields are the placeholders available to output path templates.
+type PathFields struct {
+	Series string
+	Title string
+	Season uint64
+	Episode uint64
+	LastEpisode uint64
+	EpisodeTitle string
+	AirDate string
+	Year uint64
+	Edition string
+	TVDBId uint64
+	IMDBId string
+	// Ext is always "mkv", every file is imported as Matroska.
+	Ext string
+}
+
+var templateFuncs = template.FuncMap{
+	"pad": func(number uint64) string {
+		return fmt.Sprintf("%02d", number)
+	},
+}
+
+var sampleFields = PathFields{
+	Series: "Series",
+	Title: "Title",
+	Season: 1,
+	Episode: 2,
+	LastEpisode: 3,
+	EpisodeTitle: "Episode",
+	AirDate: "2000-01-02",
+	Year: 2000,
+	Edition: "Director's Cut",
+	TVDBId: 1,
+	IMDBId: "tt0000001",
+	Ext: "mkv",
+}
+
+// compileTemplate parses a path template and checks that it renders a relative path.
+func compileTemplate(name, text, defaultText string) (pathTemplate *template.Template, err error) {
+	if text == "" {
+		text = defaultText
+	}
+
+	pathTemplate, err = template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Invalid %v template: %v", name, err))
+
+		return
+	}
+
+	var pathBuffer bytes.Buffer
+
+	if err = pathTemplate.Execute(&pathBuffer, sampleFields); err != nil {
+		err = errors.New(fmt.Sprintf("Invalid %v template: %v", name, err))
+
+		return
+	}
+
+	if reason := outsideMediaDir(pathBuffer.String()); reason != "" {
+		err = errors.New(fmt.Sprintf("Invalid %v template: %v.", name, reason))
+	}
+
+	return
+}
+
+// outsideMediaDir explains why a rendered path doesn't stay inside the media directory, or returns "" if it does.
+func outsideMediaDir(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return "must render a path relative to the media directory"
+	}
+
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part == ".." {
+			return "must not leave the media directory"
+		}
+	}
+
+	return ""
+}
+
+func (importer *NasImporter) compileTemplates() (err error) {
+	templates := &importer.config.Templates
+
+	if importer.tvEpisodeTemplate, err = compileTemplate("tv_episode", templates.TVEpisode, defaultTVEpisodeTemplate); err != nil {
+		return
+	}
+
+	if importer.documentarySeriesTemplate, err = compileTemplate("documentary_series", templates.DocumentarySeries, defaultDocumentarySeriesTemplate); err != nil {
+		return
+	}
+
+	if importer.documentaryWithYearTemplate, err = compileTemplate("documentary_with_year", templates.DocumentaryWithYear, defaultDocumentaryWithYearTemplate); err != nil {
+		return
+	}
+
+	if importer.documentaryTemplate, err = compileTemplate("documentary", templates.Documentary, defaultDocumentaryTemplate); err != nil {
+		return
+	}
+
+	importer.movieTemplate, err = compileTemplate("movie", templates.Movie, defaultMovieTemplate)
+
+	return
+}
+
+func (importer *NasImporter) buildPath(pathTemplate *template.Template, root string, fields PathFields) (outPath string, err error) {
+	// Replace ASCII slash with unicode division slash in file name parts.
+	fields.Series = strings.Replace(fields.Series, "/", "∕", -1)
+	fields.Title = strings.Replace(fields.Title, "/", "∕", -1)
+	fields.EpisodeTitle = strings.Replace(fields.EpisodeTitle, "/", "∕", -1)
+	fields.Edition = strings.Replace(fields.Edition, "/", "∕", -1)
+
+	fields.Ext = "mkv"
+
+	var pathBuffer bytes.Buffer
+
+	if err = pathTemplate.Execute(&pathBuffer, fields); err != nil {
+		return
+	}
+
+	// Provider names such as ".." could still lead the sample-checked template out of the media directory.
+	if reason := outsideMediaDir(pathBuffer.String()); reason != "" {
+		err = errors.New(fmt.Sprintf("Invalid output path %q: %v.", pathBuffer.String(), reason))
+
+		return
+	}
+
+	outPath = filepath.Join(root, pathBuffer.String())
+
+	return
+}
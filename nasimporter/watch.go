@@ -0,0 +1,359 @@
+package nasimporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"github.com/fsnotify/fsnotify"
+)
+
+type ReviewItem struct {
+	Path string `json:"path"`
+	Time time.Time `json:"time"`
+	Reason string `json:"reason"`
+}
+
+// ReviewQueue holds files that couldn't be matched confidently, one JSON object per line.
+type ReviewQueue struct {
+	path string
+	mutex sync.Mutex
+}
+
+func NewReviewQueue(path string) *ReviewQueue {
+	return &ReviewQueue{path: path}
+}
+
+func (queue *ReviewQueue) read() (items []ReviewItem, err error) {
+	handle, err := os.Open(queue.path)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+
+	defer handle.Close()
+
+	scanner := bufio.NewScanner(handle)
+
+	for scanner.Scan() {
+		item := ReviewItem{}
+
+		if err = json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return
+		}
+
+		items = append(items, item)
+	}
+
+	err = scanner.Err()
+
+	return
+}
+
+func (queue *ReviewQueue) Read() (items []ReviewItem, err error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	items, err = queue.read()
+
+	return
+}
+
+func (queue *ReviewQueue) Add(path, reason string) (err error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	items, err := queue.read()
+
+	if err != nil {
+		return
+	}
+
+	for _, item := range items {
+		if item.Path == path {
+			return
+		}
+	}
+
+	itemBytes, err := json.Marshal(ReviewItem{Path: path, Time: time.Now(), Reason: reason})
+
+	if err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(queue.path), os.ModeDir | 0755); err != nil {
+		return
+	}
+
+	handle, err := os.OpenFile(queue.path, os.O_WRONLY | os.O_APPEND | os.O_CREATE, 0644)
+
+	if err != nil {
+		return
+	}
+
+	defer handle.Close()
+
+	_, err = handle.Write(append(itemBytes, '\n'))
+
+	return
+}
+
+func (queue *ReviewQueue) Write(items []ReviewItem) (err error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	queueBytes := []byte{}
+
+	for _, item := range items {
+		itemBytes, err := json.Marshal(item)
+
+		if err != nil {
+			return err
+		}
+
+		queueBytes = append(queueBytes, append(itemBytes, '\n')...)
+	}
+
+	err = ioutil.WriteFile(queue.path, queueBytes, 0644)
+
+	return
+}
+
+// Review interactively imports every queued file, keeping the ones that still fail in the queue.
+func (importer *NasImporter) Review() (results []ImportResult, err error) {
+	items, err := importer.reviewQueue.Read()
+
+	if err != nil {
+		return
+	}
+
+	remainingItems := []ReviewItem{}
+
+	for _, item := range items {
+		if !pathExists(item.Path) {
+			continue
+		}
+
+		result := ImportResult{Path: item.Path, Err: importer.importFile(item.Path)}
+		results = append(results, result)
+
+		if result.Err != nil {
+			remainingItems = append(remainingItems, item)
+		}
+	}
+
+	if !importer.dryRun {
+		err = importer.reviewQueue.Write(remainingItems)
+	}
+
+	return
+}
+
+// maxRetryDelay caps how long a file which failed to import waits before it is tried again.
+const maxRetryDelay = time.Hour
+
+type watchedFile struct {
+	size int64
+	changed time.Time
+	attempts int
+	retry time.Time
+}
+
+func (importer *NasImporter) watchTree(watcher *fsnotify.Watcher, root string, pending map[string]watchedFile) (err error) {
+	err = filepath.Walk(root, func(path string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fileInfo.IsDir() {
+			if path != root && importer.isIgnoredName(fileInfo.Name()) {
+				return filepath.SkipDir
+			}
+
+			return watcher.Add(path)
+		}
+
+		if importer.isVideoFile(fileInfo.Name()) {
+			pending[path] = watchedFile{size: fileInfo.Size(), changed: time.Now()}
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// Watch imports video files as they appear under root, once they have stopped growing.
+// Files which don't match confidently are added to the review queue instead.
+func (importer *NasImporter) Watch(root string) (err error) {
+	return importer.watch(root, nil)
+}
+
+// watch runs Watch until stop is closed.
+func (importer *NasImporter) watch(root string, stop <-chan struct{}) (err error) {
+	root, err = filepath.Abs(root)
+
+	if err != nil {
+		return
+	}
+
+	settleTime := time.Duration(importer.config.Watch.SettleSeconds) * time.Second
+
+	if settleTime <= 0 {
+		settleTime = 30 * time.Second
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		return
+	}
+
+	defer watcher.Close()
+
+	// Files already in the folder are picked up too, they may have arrived while we weren't running.
+	pending := map[string]watchedFile{}
+
+	if err = importer.watchTree(watcher, root, pending); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+
+	defer ticker.Stop()
+
+	fmt.Fprintf(importer.out, "Watching %v\n", root)
+
+	for {
+		select {
+			case <-stop:
+				return
+
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if event.Op & (fsnotify.Create | fsnotify.Write) == 0 {
+					continue
+				}
+
+				fileInfo, err := os.Stat(event.Name)
+
+				if err != nil {
+					continue
+				}
+
+				if fileInfo.IsDir() {
+					if !importer.isIgnoredName(fileInfo.Name()) {
+						importer.watchTree(watcher, event.Name, pending)
+					}
+				} else if importer.isVideoFile(fileInfo.Name()) {
+					pending[event.Name] = watchedFile{size: fileInfo.Size(), changed: time.Now()}
+				}
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return nil
+				}
+
+				fmt.Fprintln(importer.out, err)
+
+			case now := <-ticker.C:
+				importer.importSettled(pending, now, settleTime)
+		}
+	}
+}
+
+// importSettled imports the pending files which have stopped growing, together so that the parts of a movie are
+// joined. Parts wait for each other to settle. Files which fail are tried again later, waiting twice as long after
+// each attempt, except ambiguous ones which are left in the review queue.
+func (importer *NasImporter) importSettled(pending map[string]watchedFile, now time.Time, settleTime time.Duration) {
+	files := []ImportResult{}
+	settled := map[string]bool{}
+
+	for path, file := range pending {
+		fileInfo, err := os.Stat(path)
+
+		if err != nil {
+			delete(pending, path)
+
+			continue
+		}
+
+		if fileInfo.Size() != file.size {
+			pending[path] = watchedFile{size: fileInfo.Size(), changed: now, attempts: file.attempts, retry: file.retry}
+
+			continue
+		}
+
+		files = append(files, ImportResult{Path: path})
+		settled[path] = now.Sub(file.changed) >= settleTime && !now.Before(file.retry)
+	}
+
+	partSets, _ := importer.groupParts(files)
+
+	for _, parts := range partSets {
+		ready := true
+
+		for _, part := range parts {
+			ready = ready && settled[part]
+		}
+
+		for _, part := range parts {
+			settled[part] = ready
+		}
+	}
+
+	paths := []string{}
+
+	for path, ready := range settled {
+		if ready {
+			paths = append(paths, path)
+		}
+	}
+
+	if len(paths) == 0 {
+		return
+	}
+
+	sort.Strings(paths)
+
+	for _, result := range importer.Import(paths...) {
+		file := pending[result.Path]
+
+		switch {
+			case result.Err == nil:
+				fmt.Fprintf(importer.out, "Imported %v\n", result.Path)
+				delete(pending, result.Path)
+
+			case errors.Is(result.Err, ErrQueuedForReview):
+				fmt.Fprintf(importer.out, "%v: %v\n", result.Path, result.Err)
+				delete(pending, result.Path)
+
+			default:
+				delay := settleTime
+
+				for attempt := 0; attempt < file.attempts && delay < maxRetryDelay; attempt++ {
+					delay *= 2
+				}
+
+				if delay > maxRetryDelay {
+					delay = maxRetryDelay
+				}
+
+				file.attempts++
+				file.retry = now.Add(delay)
+				pending[result.Path] = file
+				fmt.Fprintf(importer.out, "%v: %v, trying again in %v\n", result.Path, result.Err, delay)
+		}
+	}
+}